@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/writer"
+	"github.com/zinrai/prom-textfile-exporter/pkg/textfile"
+)
+
+// prints what a run would execute and where it would write, without executing
+// commands, taking locks or writing files
+func printDryRun(cfg, selected *config.Config, opts runOptions) {
+	fmt.Printf("Dry run of %s: %d of %d metrics selected, nothing is executed\n", opts.configFile, len(selected.Metrics), len(cfg.Metrics))
+	fmt.Printf("Commands run with sh -c as %s, with the environment of this process\n", currentUser())
+	if opts.replayFile != "" {
+		fmt.Printf("Command results are replayed from %s\n", opts.replayFile)
+	}
+	if opts.stateDir == "" {
+		fmt.Println("State is not kept across runs (no -state-dir)")
+	} else {
+		fmt.Printf("State is kept in %s\n", opts.stateDir)
+	}
+	if opts.outputDir != "" {
+		fmt.Printf("Files are written with mode %04o, owned by %s\n", opts.fileOptions.Mode.Perm(), fileOwner(opts.fileOptions))
+	}
+
+	for _, key := range textfile.CollectionOrder(selected) {
+		metricCfg := selected.Metrics[key]
+		collectorCfg := metricCfg.Collector
+
+		fmt.Printf("\n%s (%s %s)\n", key, metricCfg.Type, metricCfg.Name)
+		fmt.Printf("  collector: %s\n", collectorCfg.Type)
+		if derived := collectorCfg.Derived; derived != nil {
+			fmt.Printf("  computes:  %s of %s\n", derived.Function, formatSelector(derived.Selector))
+			if derived.Denominator != nil {
+				fmt.Printf("  over:      %s\n", formatSelector(*derived.Denominator))
+			}
+		} else {
+			fmt.Printf("  command:   %s\n", collectorCfg.Command)
+			fmt.Printf("  timeout:   %ds\n", opts.timeoutSec)
+		}
+		if len(collectorCfg.Labels) > 0 {
+			fmt.Printf("  labels:    %s\n", formatSelector(config.Selector{Match: collectorCfg.Labels}))
+		}
+		if collectorCfg.CacheTTL > 0 {
+			if opts.stateDir == "" {
+				fmt.Printf("  cache_ttl: %s (requires -state-dir)\n", collectorCfg.CacheTTL)
+			} else {
+				fmt.Printf("  cache_ttl: %s\n", collectorCfg.CacheTTL)
+			}
+		}
+		if len(metricCfg.Tags) > 0 {
+			fmt.Printf("  tags:      %s\n", strings.Join(metricCfg.Tags, ", "))
+		}
+		if opts.outputDir == "" {
+			fmt.Printf("  output:    stdout\n")
+		} else {
+			fmt.Printf("  output:    %s\n", filepath.Join(opts.outputDir, outputFileName(metricCfg.Output, opts.outputFile)))
+		}
+	}
+}
+
+// describes the user commands run as
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return "the current user"
+	}
+	return fmt.Sprintf("user %s (uid %s)", u.Username, u.Uid)
+}
+
+// describes the owner of written files, which -file-owner may change
+func fileOwner(opts writer.FileOptions) string {
+	owner := "the current user"
+	if opts.UID != -1 {
+		owner = fmt.Sprintf("uid %d", opts.UID)
+		if u, err := user.LookupId(strconv.Itoa(opts.UID)); err == nil {
+			owner = fmt.Sprintf("user %s (uid %d)", u.Username, opts.UID)
+		}
+	}
+	if opts.GID != -1 {
+		owner += fmt.Sprintf(", gid %d", opts.GID)
+	}
+	return owner
+}
+
+// formats a metric name with label values, such as the series a derived metric selects
+func formatSelector(selector config.Selector) string {
+	if len(selector.Match) == 0 {
+		return selector.Metric
+	}
+
+	labels := make([]string, 0, len(selector.Match))
+	for k, v := range selector.Match {
+		labels = append(labels, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(labels)
+
+	return fmt.Sprintf("%s{%s}", selector.Metric, strings.Join(labels, ","))
+}
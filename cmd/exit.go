@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// exit codes of the process, so that service managers and cron monitoring
+// can tell degraded collection apart from success
+const (
+	exitOK        = 0 // all metrics collected, or collected with warnings without -strict
+	exitFailure   = 1 // the run failed, e.g. the output could not be written
+	exitUsage     = 2 // invalid command line
+	exitWarnings  = 3 // with -strict: some metrics used a default or last value, or had other warnings
+	exitPartial   = 4 // some metrics could not be collected
+	exitNoMetrics = 5 // no metric could be collected
+	exitConfig    = 6 // the configuration could not be loaded
+)
+
+// logs an error with the given attributes and exits with the given code
+func fatal(code int, msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(code)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// makes the default logger write to stderr at the given level, as text or as
+// one JSON object per line for log pipelines
+func setupLogging(level, format string) error {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %s (must be debug, info, warn or error)", level)
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, handlerOptions)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, handlerOptions)
+	default:
+		return fmt.Errorf("invalid log format %s (must be text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/user"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/zinrai/prom-textfile-exporter/internal/collector"
 	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+	"github.com/zinrai/prom-textfile-exporter/internal/lock"
+	"github.com/zinrai/prom-textfile-exporter/internal/report"
 	"github.com/zinrai/prom-textfile-exporter/internal/writer"
+	"github.com/zinrai/prom-textfile-exporter/pkg/textfile"
 )
 
 var (
@@ -18,6 +30,29 @@ var (
 	date    = "unknown"
 )
 
+const (
+	// advisory lock serializing runs that write to the same output directory
+	lockFileName = ".prom_textfile_exporter.lock"
+)
+
+type runOptions struct {
+	configFile   string
+	outputDir    string
+	outputFile   string
+	fileOptions  writer.FileOptions
+	stateDir     string
+	timeoutSec   int
+	lockTimeout  int
+	skipIfLocked bool
+	reportFormat string
+	reportFile   string
+	recordFile   string
+	replayFile   string
+	selection    config.Selection
+	dryRun       bool
+	strict       bool
+}
+
 func printVersion() {
 	fmt.Printf("prom-textfile-exporter %s (commit %s, built %s)\n", version, commit, date)
 }
@@ -26,10 +61,13 @@ func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("Usage: prom-textfile-exporter <command> [command options]")
 		fmt.Println("\nCommands:")
-		fmt.Println("  run       Execute metric collection")
-		fmt.Println("  validate  Validate configuration file")
+		fmt.Println("  run          Execute metric collection")
+		fmt.Println("  validate     Validate configuration file")
+		fmt.Println("  test         Collect one metric and trace how its value is obtained")
+		fmt.Println("  test-config  Run the fixture tests of a configuration")
+		fmt.Println("  schema       Print the JSON Schema of the configuration file")
 		fmt.Println("\nRun 'prom-textfile-exporter <command> -h' for help on a specific command")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	command := os.Args[1]
@@ -39,15 +77,24 @@ func main() {
 		runCommand(os.Args[2:])
 	case "validate":
 		validateCommand(os.Args[2:])
+	case "test":
+		testCommand(os.Args[2:])
+	case "test-config":
+		testConfigCommand(os.Args[2:])
+	case "schema":
+		schemaCommand(os.Args[2:])
 	case "version":
 		printVersion()
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		fmt.Println("Usage: prom-textfile-exporter <command> [command options]")
 		fmt.Println("\nCommands:")
-		fmt.Println("  run       Execute metric collection")
-		fmt.Println("  validate  Validate configuration file")
-		os.Exit(1)
+		fmt.Println("  run          Execute metric collection")
+		fmt.Println("  validate     Validate configuration file")
+		fmt.Println("  test         Collect one metric and trace how its value is obtained")
+		fmt.Println("  test-config  Run the fixture tests of a configuration")
+		fmt.Println("  schema       Print the JSON Schema of the configuration file")
+		os.Exit(exitUsage)
 	}
 }
 
@@ -56,7 +103,24 @@ func runCommand(args []string) {
 
 	configFile := runFlags.String("config", "./config.yaml", "Path to configuration file")
 	outputDir := runFlags.String("output-dir", "", "Output directory (if not specified, output to stdout)")
+	outputFile := runFlags.String("output-file", "prom_textfile_exporter.prom", "Output file name for metrics without an output group")
+	fileMode := runFlags.String("file-mode", "0644", "Permission bits of written metric files (octal)")
+	fileOwner := runFlags.String("file-owner", "", "Owner of written metric files as user[:group] (default: current user)")
+	stateDir := runFlags.String("state-dir", "", "Directory for state kept across runs (if not specified, state is not persisted)")
 	timeoutSec := runFlags.Int("timeout", 10, "Command execution timeout in seconds")
+	lockTimeout := runFlags.Int("lock-timeout", 30, "Seconds to wait for another run holding the output directory lock")
+	skipIfLocked := runFlags.Bool("skip-if-locked", false, "Skip the run instead of failing if the output directory lock cannot be acquired")
+	reportFormat := runFlags.String("report", "", "Run report format (json), printed to stderr unless -report-file is set")
+	reportFile := runFlags.String("report-file", "", "Write the run report to this file (implies -report json)")
+	recordFile := runFlags.String("record", "", "Record the output and exit code of each executed command to this file")
+	replayFile := runFlags.String("replay", "", "Replay command results from a file written by -record instead of executing commands")
+	only := runFlags.String("only", "", "Collect only these metrics (comma-separated keys or names)")
+	exclude := runFlags.String("exclude", "", "Do not collect these metrics (comma-separated keys or names)")
+	tags := runFlags.String("tags", "", "Collect only metrics with one of these tags (comma-separated)")
+	strict := runFlags.Bool("strict", false, "Exit with an error if any metric had a warning, e.g. used a default value")
+	logLevel := runFlags.String("log-level", "info", "Log level (debug, info, warn, error); debug logs each metric and its command output")
+	logFormat := runFlags.String("log-format", "text", "Log format (text, json)")
+	dryRun := runFlags.Bool("dry-run", false, "Print the command, timeout and output target of each selected metric without executing anything")
 
 	runFlags.Usage = func() {
 		fmt.Println("Usage: prom-textfile-exporter run [options]")
@@ -67,10 +131,64 @@ func runCommand(args []string) {
 	if err := runFlags.Parse(args); err != nil {
 		fmt.Println(err)
 		runFlags.Usage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
-	runExecute(*configFile, *outputDir, *timeoutSec)
+	if *outputFile == "" || *outputFile != filepath.Base(*outputFile) || !strings.HasSuffix(*outputFile, ".prom") {
+		fmt.Printf("Invalid output file name: %s (must be a file name ending in .prom)\n", *outputFile)
+		runFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	fileOptions, err := parseFileOptions(*fileMode, *fileOwner)
+	if err != nil {
+		fmt.Println(err)
+		runFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if *reportFile != "" && *reportFormat == "" {
+		*reportFormat = "json"
+	}
+	if *reportFormat != "" && *reportFormat != "json" {
+		fmt.Printf("Unsupported report format: %s\n", *reportFormat)
+		runFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fmt.Println(err)
+		runFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if *recordFile != "" && *replayFile != "" {
+		fmt.Println("-record and -replay cannot be used together")
+		runFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	runExecute(runOptions{
+		configFile:   *configFile,
+		outputDir:    *outputDir,
+		outputFile:   *outputFile,
+		fileOptions:  fileOptions,
+		stateDir:     *stateDir,
+		timeoutSec:   *timeoutSec,
+		lockTimeout:  *lockTimeout,
+		skipIfLocked: *skipIfLocked,
+		reportFormat: *reportFormat,
+		reportFile:   *reportFile,
+		recordFile:   *recordFile,
+		replayFile:   *replayFile,
+		selection: config.Selection{
+			Only:    splitList(*only),
+			Exclude: splitList(*exclude),
+			Tags:    splitList(*tags),
+		},
+		dryRun: *dryRun,
+		strict: *strict,
+	})
 }
 
 func validateCommand(args []string) {
@@ -87,89 +205,325 @@ func validateCommand(args []string) {
 	if err := validateFlags.Parse(args); err != nil {
 		fmt.Println(err)
 		validateFlags.Usage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	validateExecute(*configFile)
 }
 
-func runExecute(configFile, outputDir string, timeoutSec int) {
-	log.Printf("Loading configuration from: %s", configFile)
+func runExecute(opts runOptions) {
+	slog.Info("Loading configuration", "config", opts.configFile)
 
-	cfg, err := config.LoadConfig(configFile)
+	cfg, err := config.LoadConfig(opts.configFile)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal(exitConfig, "Failed to load configuration", "config", opts.configFile, "error", err)
 	}
 
-	metrics := []collector.Metric{}
-	hasErrors := false
-	hasWarnings := false
+	// The full configuration still decides which output files exist
+	selected := cfg
+	if !opts.selection.Empty() {
+		selected, err = opts.selection.Apply(cfg)
+		if err != nil {
+			fatal(exitUsage, "Invalid metric selection", "error", err)
+		}
+		slog.Info("Collecting selected metrics", "selected", len(selected.Metrics), "configured", len(cfg.Metrics))
 
-	for name, metricCfg := range cfg.Metrics {
-		log.Printf("Collecting metric: %s", name)
+		if opts.outputDir != "" {
+			if err := checkSelectedOutputs(cfg, selected, opts.outputFile); err != nil {
+				fatal(exitUsage, "Invalid metric selection, write to stdout instead or select whole output groups", "error", err)
+			}
+		}
+	}
 
-		col, err := collector.NewCollector(metricCfg, timeoutSec)
-		if err != nil {
-			log.Printf("Error creating collector for %s: %v", name, err)
-			hasErrors = true
-			continue
-		}
-
-		result := col.Collect()
-
-		if result.Error != nil {
-			// If there is an error but valid metrics
-			if result.MetricValid {
-				log.Printf("Warning collecting metric %s: %v", name, result.Error)
-				hasWarnings = true
-				metrics = append(metrics, result.Metric)
-			} else {
-				// If there is an error and no valid metrics
-				log.Printf("Error collecting metric %s: %v", name, result.Error)
-				hasErrors = true
+	if opts.dryRun {
+		printDryRun(cfg, selected, opts)
+		return
+	}
+
+	if opts.outputDir != "" {
+		if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+			fatal(exitFailure, "Failed to create output directory", "output_dir", opts.outputDir, "error", err)
+		}
+
+		// Prevent overlapping runs from racing on the same output directory
+		outputLock, err := lock.Acquire(filepath.Join(opts.outputDir, lockFileName), time.Duration(opts.lockTimeout)*time.Second)
+		if errors.Is(err, lock.ErrLocked) {
+			if err := lock.IncrementCounter(filepath.Join(opts.outputDir, skippedRunsFileName(opts.outputFile))); err != nil {
+				slog.Warn("Failed to record skipped run", "error", err)
 			}
-		} else if result.MetricValid {
-			metrics = append(metrics, result.Metric)
+			if opts.skipIfLocked {
+				slog.Info("Output directory is locked by another run, skipping", "output_dir", opts.outputDir)
+				return
+			}
+			fatal(exitFailure, "Timed out waiting for the lock on the output directory", "output_dir", opts.outputDir, "lock_timeout", time.Duration(opts.lockTimeout)*time.Second)
+		}
+		if err != nil {
+			fatal(exitFailure, "Failed to lock output directory", "output_dir", opts.outputDir, "error", err)
+		}
+		defer outputLock.Release()
+	}
+
+	exec, recorder, err := newExecutor(opts.recordFile, opts.replayFile)
+	if err != nil {
+		fatal(exitFailure, "Failed to set up command execution", "error", err)
+	}
+
+	runner := textfile.Runner{
+		Timeout:     time.Duration(opts.timeoutSec) * time.Second,
+		StateDir:    opts.stateDir,
+		LockTimeout: time.Duration(opts.lockTimeout) * time.Second,
+		Executor:    exec,
+		Configured:  cfg,
+	}
+	metrics, runReport, err := runner.Run(context.Background(), selected)
+	if err != nil {
+		fatal(exitFailure, "Failed to run collection", "error", err)
+	}
+	runReport.ConfigFile = opts.configFile
+
+	if recorder != nil {
+		if err := recorder.Save(opts.recordFile); err != nil {
+			slog.Warn("Failed to save command recording", "file", opts.recordFile, "error", err)
+		} else {
+			slog.Info("Recorded command results", "file", opts.recordFile)
 		}
 	}
 
-	if len(metrics) == 0 {
-		log.Fatalf("No metrics were collected")
+	if opts.reportFormat != "" {
+		writeReport(runReport, opts.reportFile)
 	}
 
+	outputDir := opts.outputDir
 	if outputDir == "" {
+		if len(metrics) == 0 {
+			fatal(exitNoMetrics, "No metrics were collected")
+		}
+
 		// Output to stdout
 		if err := writer.WriteMetricsToStdout(metrics); err != nil {
-			log.Fatalf("Failed to write metrics to stdout: %v", err)
+			fatal(exitFailure, "Failed to write metrics to stdout", "error", err)
 		}
 	} else {
-		// Output to file with atomic write
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			log.Fatalf("Failed to create output directory: %v", err)
+		// Every output file of the selected metrics is rewritten, also when none
+		// of its metrics could be collected, so that it never serves old values
+		writable := writableOutputFiles(cfg, selected, opts.outputFile)
+		outputs := make(map[string][]collector.Metric)
+		for file, ok := range writable {
+			if ok {
+				outputs[file] = []collector.Metric{}
+			}
+		}
+		for _, metric := range metrics {
+			target := outputFileName(metric.Output, opts.outputFile)
+			outputs[target] = append(outputs[target], metric)
+		}
+
+		// Report runs that were skipped while waiting for the lock, unless the
+		// default file belongs to metrics that were not selected
+		skipped, err := lock.ReadCounter(filepath.Join(outputDir, skippedRunsFileName(opts.outputFile)))
+		if err != nil {
+			slog.Warn("Failed to read skipped run count", "error", err)
+		} else if writable[opts.outputFile] {
+			outputs[opts.outputFile] = append(outputs[opts.outputFile], collector.Metric{
+				Name:   "prom_textfile_exporter_skipped_runs_total",
+				Value:  float64(skipped),
+				Type:   "counter",
+				Help:   "Number of runs skipped because another run held the output directory lock",
+				Labels: map[string]string{"output_file": opts.outputFile},
+			})
+		}
+
+		// Output to file with atomic write, each output group is written to its own file
+		files := make([]string, 0, len(outputs))
+		for file := range outputs {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			outputFile := filepath.Join(outputDir, file)
+			if err := writer.WriteMetricsToFile(outputs[file], outputFile, opts.fileOptions); err != nil {
+				fatal(exitFailure, "Failed to write metrics to file", "file", outputFile, "error", err)
+			}
+
+			slog.Info("Wrote metrics", "file", outputFile, "series", len(outputs[file]))
+		}
+
+		// Remove files of output groups that are no longer configured
+		configured := configuredOutputFiles(cfg, opts.outputFile)
+		if err := writer.RemoveStaleFiles(outputDir, writer.ManifestName(opts.outputFile), configured); err != nil {
+			slog.Warn("Failed to remove stale output files", "error", err)
 		}
 
-		outputFile := filepath.Join(outputDir, "prom_textfile_exporter.prom")
-		if err := writer.WriteMetricsToFile(metrics, outputFile); err != nil {
-			log.Fatalf("Failed to write metrics to file: %v", err)
+		if len(metrics) == 0 {
+			fatal(exitNoMetrics, "No metrics were collected")
 		}
+	}
 
-		log.Printf("Successfully wrote %d metrics to %s", len(metrics), outputFile)
+	warnings, errors := runReport.Problems()
+	switch {
+	case errors > 0:
+		fatal(exitPartial, "Some errors occurred during collection, not all metrics were generated", "failed", errors, "warnings", warnings)
+	case warnings > 0 && opts.strict:
+		fatal(exitWarnings, "Some warnings occurred during collection, failing because of -strict", "warnings", warnings)
+	case warnings > 0:
+		slog.Warn("Some warnings occurred during collection, but metrics were still generated", "warnings", warnings)
 	}
+}
 
-	if hasWarnings {
-		log.Printf("Some warnings occurred during collection, but metrics were still generated")
+// parses the -file-mode and -file-owner flags
+func parseFileOptions(mode, owner string) (writer.FileOptions, error) {
+	opts := writer.DefaultFileOptions()
+
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return opts, fmt.Errorf("invalid file mode: %s", mode)
 	}
-	if hasErrors {
-		log.Printf("Some errors occurred during collection, not all metrics were generated")
+	opts.Mode = os.FileMode(perm)
+
+	if owner == "" {
+		return opts, nil
+	}
+
+	userName, groupName, hasGroup := strings.Cut(owner, ":")
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return opts, fmt.Errorf("invalid file owner: %w", err)
+		}
+		if opts.UID, err = strconv.Atoi(u.Uid); err != nil {
+			return opts, fmt.Errorf("invalid file owner: non-numeric uid %s", u.Uid)
+		}
+	}
+	if hasGroup && groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return opts, fmt.Errorf("invalid file owner: %w", err)
+		}
+		if opts.GID, err = strconv.Atoi(g.Gid); err != nil {
+			return opts, fmt.Errorf("invalid file owner: non-numeric gid %s", g.Gid)
+		}
+	}
+
+	return opts, nil
+}
+
+// returns the file name of an output group in the output directory
+func outputFileName(output string, defaultFile string) string {
+	if output == "" {
+		return defaultFile
+	}
+	return output + ".prom"
+}
+
+// returns the sorted set of output file names used by the configuration, including the default file
+func configuredOutputFiles(cfg *config.Config, defaultFile string) []string {
+	// The default file always exists as it also holds meta-metrics
+	seen := map[string]bool{defaultFile: true}
+	files := []string{defaultFile}
+	for _, metricCfg := range cfg.Metrics {
+		file := outputFileName(metricCfg.Output, defaultFile)
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// returns the name of the file counting the skipped runs of the configuration
+// written to outputFile, so that configurations sharing an output directory
+// count their skipped runs separately
+func skippedRunsFileName(outputFile string) string {
+	return "." + strings.TrimSuffix(outputFile, ".prom") + ".skipped"
+}
+
+// returns the output files whose metrics are all selected, which a run may
+// rewrite without losing the metrics of other runs
+func writableOutputFiles(cfg, selected *config.Config, defaultFile string) map[string]bool {
+	writable := make(map[string]bool)
+	for _, file := range configuredOutputFiles(cfg, defaultFile) {
+		writable[file] = true
+	}
+	for key, metricCfg := range cfg.Metrics {
+		if _, ok := selected.Metrics[key]; !ok {
+			writable[outputFileName(metricCfg.Output, defaultFile)] = false
+		}
+	}
+	return writable
+}
+
+// checks that a selection covers whole output groups, as writing the file of
+// a partly selected group would remove the metrics that were not selected
+func checkSelectedOutputs(cfg, selected *config.Config, defaultFile string) error {
+	writable := writableOutputFiles(cfg, selected, defaultFile)
+
+	var partial []string
+	for _, metricCfg := range selected.Metrics {
+		file := outputFileName(metricCfg.Output, defaultFile)
+		if !writable[file] && !slices.Contains(partial, file) {
+			partial = append(partial, file)
+		}
+	}
+	sort.Strings(partial)
+
+	if len(partial) > 0 {
+		return fmt.Errorf("only some metrics of %s are selected", strings.Join(partial, ", "))
+	}
+	return nil
+}
+
+// splits a comma-separated list such as "disk, network" into its items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// returns the executor of a run, which replays a recording, records the
+// executed commands, or executes them with the shell
+func newExecutor(recordFile, replayFile string) (executor.Executor, *executor.RecordingExecutor, error) {
+	if replayFile != "" {
+		replay, err := executor.LoadReplayExecutor(replayFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return replay, nil, nil
+	}
+
+	if recordFile != "" {
+		recorder := executor.NewRecordingExecutor(executor.ShellExecutor{})
+		return recorder, recorder, nil
+	}
+
+	return executor.ShellExecutor{}, nil, nil
+}
+
+// writes the run report to a file, or to stderr if no file is given
+func writeReport(runReport *report.Report, reportFile string) {
+	if reportFile == "" {
+		if err := runReport.WriteJSON(os.Stderr); err != nil {
+			slog.Warn("Failed to write run report", "error", err)
+		}
+		return
+	}
+
+	if err := runReport.WriteJSONFile(reportFile); err != nil {
+		slog.Warn("Failed to write run report", "file", reportFile, "error", err)
 	}
 }
 
 func validateExecute(configFile string) {
-	log.Printf("Validating configuration in: %s", configFile)
+	slog.Info("Validating configuration", "config", configFile)
 
 	_, err := config.LoadConfig(configFile)
 	if err != nil {
-		log.Fatalf("Configuration validation failed: %v", err)
+		fatal(exitConfig, "Configuration validation failed", "config", configFile, "error", err)
 	}
 
 	fmt.Println("Configuration is valid.")
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+)
+
+func schemaCommand(args []string) {
+	schemaFlags := flag.NewFlagSet("schema", flag.ExitOnError)
+
+	schemaFlags.Usage = func() {
+		fmt.Println("Usage: prom-textfile-exporter schema > config.schema.json")
+		fmt.Println("\nPrints the JSON Schema of the configuration file, for validation and completion in editors")
+	}
+
+	if err := schemaFlags.Parse(args); err != nil {
+		fmt.Println(err)
+		schemaFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	schemaExecute()
+}
+
+// prints the JSON Schema of the configuration file
+func schemaExecute() {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config.Schema()); err != nil {
+		fatal(exitFailure, "Failed to write schema", "error", err)
+	}
+}
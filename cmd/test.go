@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+	"github.com/zinrai/prom-textfile-exporter/internal/writer"
+)
+
+func testCommand(args []string) {
+	testFlags := flag.NewFlagSet("test", flag.ExitOnError)
+
+	configFile := testFlags.String("config", "./config.yaml", "Path to configuration file")
+	metricKey := testFlags.String("metric", "", "Key or name of the metric to test")
+	timeoutSec := testFlags.Int("timeout", 10, "Command execution timeout in seconds")
+	replayFile := testFlags.String("replay", "", "Replay command results from a file written by run -record instead of executing commands")
+
+	testFlags.Usage = func() {
+		fmt.Println("Usage: prom-textfile-exporter test -metric <metric> [options]")
+		fmt.Println("\nOptions:")
+		testFlags.PrintDefaults()
+	}
+
+	if err := testFlags.Parse(args); err != nil {
+		fmt.Println(err)
+		testFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if *metricKey == "" {
+		fmt.Println("A metric is required")
+		testFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	testExecute(*configFile, *metricKey, *timeoutSec, *replayFile)
+}
+
+// collects a single metric and prints each step from the command to the
+// exposition lines, without reading or writing any state
+func testExecute(configFile, metricKey string, timeoutSec int, replayFile string) {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		fatal(exitConfig, "Failed to load configuration", "config", configFile, "error", err)
+	}
+
+	exec, _, err := newExecutor("", replayFile)
+	if err != nil {
+		fatal(exitFailure, "Failed to set up command execution", "error", err)
+	}
+
+	key, err := findMetric(cfg, metricKey)
+	if err != nil {
+		fatal(exitUsage, "Metric not found", "error", err)
+	}
+	metricCfg := cfg.Metrics[key]
+
+	c, err := collector.NewCollector(metricCfg, timeoutSec, exec)
+	if err != nil {
+		fatal(exitFailure, "Failed to create collector", "metric", key, "error", err)
+	}
+
+	fmt.Printf("Metric:     %s (%s)\n", key, metricCfg.Name)
+	fmt.Printf("Collector:  %s\n", metricCfg.Collector.Type)
+
+	// Derived metrics need the metrics they are computed from
+	inputCollector, derived := c.(collector.InputCollector)
+	if derived {
+		inputs := collectInputs(cfg, key, timeoutSec, exec)
+		fmt.Printf("Inputs:     %d series\n", len(inputs))
+		inputCollector.SetInputs(inputs)
+	} else {
+		fmt.Printf("Command:    %s\n", metricCfg.Collector.Command)
+	}
+
+	var steps []string
+	if traceable, ok := c.(collector.TraceableCollector); ok {
+		traceable.SetTracer(func(format string, args ...any) {
+			steps = append(steps, fmt.Sprintf(format, args...))
+		})
+	}
+
+	result := c.Collect()
+
+	if !derived {
+		fmt.Printf("Exit code:  %d\n", result.ExitCode)
+		fmt.Printf("Duration:   %s\n", result.Duration)
+		if result.TimedOut {
+			fmt.Printf("Timed out:  after %d seconds\n", timeoutSec)
+		}
+		fmt.Println("\nOutput:")
+		printIndented(result.Output)
+	}
+
+	if len(steps) > 0 {
+		fmt.Println("\nParse:")
+		printIndented(strings.Join(steps, "\n"))
+	}
+
+	if result.Error != nil {
+		if result.HasWarning {
+			fmt.Printf("\nWarning: %v\n", result.Error)
+		} else {
+			fmt.Printf("\nError: %v\n", result.Error)
+		}
+	}
+
+	if !result.MetricValid {
+		os.Exit(exitFailure)
+	}
+
+	fmt.Println("\nResult:")
+	printIndented(writer.FormatMetrics(result.Metrics))
+}
+
+// returns the key of the metric with the given key, or of the only metric with the given name
+func findMetric(cfg *config.Config, metric string) (string, error) {
+	if _, ok := cfg.Metrics[metric]; ok {
+		return metric, nil
+	}
+
+	var keys []string
+	for key, metricCfg := range cfg.Metrics {
+		if metricCfg.Name == metric {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	switch len(keys) {
+	case 0:
+		return "", fmt.Errorf("metric %s not found in configuration", metric)
+	case 1:
+		return keys[0], nil
+	default:
+		return "", fmt.Errorf("metric name %s is used by several metrics, use one of the keys: %s", metric, strings.Join(keys, ", "))
+	}
+}
+
+// collects the metrics a derived metric is computed from
+func collectInputs(cfg *config.Config, key string, timeoutSec int, exec executor.Executor) []collector.Metric {
+	var inputs []collector.Metric
+	for _, inputKey := range cfg.DerivedInputs(key) {
+		c, err := collector.NewCollector(cfg.Metrics[inputKey], timeoutSec, exec)
+		if err != nil {
+			slog.Warn("Failed to create collector", "metric", inputKey, "error", err)
+			continue
+		}
+		result := c.Collect()
+		if !result.MetricValid {
+			slog.Warn("Failed to collect input metric", "metric", inputKey, "error", result.Error)
+			continue
+		}
+		inputs = append(inputs, result.Metrics...)
+	}
+
+	return inputs
+}
+
+// prints text with each line indented
+func printIndented(text string) {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		fmt.Println("  (empty)")
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+}
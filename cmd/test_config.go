@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+func testConfigCommand(args []string) {
+	testConfigFlags := flag.NewFlagSet("test-config", flag.ExitOnError)
+
+	configFile := testConfigFlags.String("config", "./config.yaml", "Path to configuration file")
+	metricKey := testConfigFlags.String("metric", "", "Only run the tests of this metric key")
+
+	testConfigFlags.Usage = func() {
+		fmt.Println("Usage: prom-textfile-exporter test-config [options]")
+		fmt.Println("\nOptions:")
+		testConfigFlags.PrintDefaults()
+	}
+
+	if err := testConfigFlags.Parse(args); err != nil {
+		fmt.Println(err)
+		testConfigFlags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	testConfigExecute(*configFile, *metricKey)
+}
+
+// runs the fixture tests of the configuration without executing any command
+func testConfigExecute(configFile, metricKey string) {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		fatal(exitConfig, "Failed to load configuration", "config", configFile, "error", err)
+	}
+
+	if metricKey != "" {
+		if _, ok := cfg.Metrics[metricKey]; !ok {
+			fatal(exitUsage, "Metric not found in configuration", "metric", metricKey)
+		}
+	}
+
+	var keys []string
+	for key := range cfg.Metrics {
+		if metricKey == "" || key == metricKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	total, failed := 0, 0
+	for _, key := range keys {
+		metricCfg := cfg.Metrics[key]
+		for i, test := range metricCfg.Tests {
+			name := test.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			total++
+
+			if err := runMetricTest(metricCfg, test, filepath.Dir(configFile)); err != nil {
+				failed++
+				fmt.Printf("FAIL %s/%s: %v\n", key, name, err)
+				continue
+			}
+			fmt.Printf("PASS %s/%s\n", key, name)
+		}
+	}
+
+	if total == 0 {
+		fmt.Println("No tests found.")
+		return
+	}
+
+	fmt.Printf("\n%d tests, %d passed, %d failed\n", total, total-failed, failed)
+	if failed > 0 {
+		os.Exit(exitFailure)
+	}
+}
+
+// collects a metric from the fixture of a test and compares the result with
+// the expectation
+func runMetricTest(metricCfg config.MetricConfig, test config.MetricTest, configDir string) error {
+	output := test.Output
+	if test.OutputFile != "" {
+		path := test.OutputFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(configDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read output file: %w", err)
+		}
+		output = string(data)
+	}
+
+	c, err := collector.NewCollector(metricCfg, 0, executor.FixtureExecutor{Output: output, ExitCode: test.ExitCode})
+	if err != nil {
+		return fmt.Errorf("could not create collector: %w", err)
+	}
+	result := c.Collect()
+
+	if test.Error != "" {
+		if result.Error == nil {
+			return fmt.Errorf("expected error containing %q, got none", test.Error)
+		}
+		if !strings.Contains(result.Error.Error(), test.Error) {
+			return fmt.Errorf("expected error containing %q, got %q", test.Error, result.Error)
+		}
+		return nil
+	}
+
+	if !result.MetricValid {
+		return fmt.Errorf("unexpected error: %v", result.Error)
+	}
+
+	if test.Value != nil {
+		if len(result.Metrics) != 1 {
+			return fmt.Errorf("expected a single series, got %d", len(result.Metrics))
+		}
+		metric := result.Metrics[0]
+		if !equalValues(metric.Value, *test.Value) {
+			return fmt.Errorf("expected value %g, got %g", *test.Value, metric.Value)
+		}
+		if !hasLabels(metric.Labels, test.Labels) {
+			return fmt.Errorf("expected labels %v, got %v", test.Labels, metric.Labels)
+		}
+		return nil
+	}
+
+	var series []collector.Metric
+	for _, metric := range result.Metrics {
+		series = append(series, exposedSeries(metric)...)
+	}
+	if len(series) != len(test.Series) {
+		return fmt.Errorf("expected %d series, got %d", len(test.Series), len(series))
+	}
+	for _, expected := range test.Series {
+		name := expected.Name
+		if name == "" {
+			name = metricCfg.Name
+		}
+		if !containsSeries(series, name, expected) {
+			if len(expected.Labels) > 0 {
+				return fmt.Errorf("expected series %s with labels %v and value %g not found", name, expected.Labels, expected.Value)
+			}
+			return fmt.Errorf("expected series %s with value %g not found", name, expected.Value)
+		}
+	}
+
+	return nil
+}
+
+// returns the series of a metric as they are written, such as the _bucket,
+// _sum and _count series of a histogram or one series per state of a stateset
+func exposedSeries(metric collector.Metric) []collector.Metric {
+	series := func(name string, value float64, label, labelValue string) collector.Metric {
+		labels := make(map[string]string, len(metric.Labels)+1)
+		for k, v := range metric.Labels {
+			labels[k] = v
+		}
+		if label != "" {
+			labels[label] = labelValue
+		}
+		return collector.Metric{Name: name, Value: value, Labels: labels}
+	}
+
+	var result []collector.Metric
+	switch metric.Type {
+	case "histogram":
+		for _, bucket := range metric.Buckets {
+			result = append(result, series(metric.Name+"_bucket", float64(bucket.Count), "le", strconv.FormatFloat(bucket.UpperBound, 'g', -1, 64)))
+		}
+		result = append(result,
+			series(metric.Name+"_bucket", float64(metric.Count), "le", "+Inf"),
+			series(metric.Name+"_sum", metric.Sum, "", ""),
+			series(metric.Name+"_count", float64(metric.Count), "", ""))
+	case "summary":
+		for _, quantile := range metric.Quantiles {
+			result = append(result, series(metric.Name, quantile.Value, "quantile", strconv.FormatFloat(quantile.Quantile, 'g', -1, 64)))
+		}
+		result = append(result,
+			series(metric.Name+"_sum", metric.Sum, "", ""),
+			series(metric.Name+"_count", float64(metric.Count), "", ""))
+	case "stateset":
+		for _, state := range metric.States {
+			value := 0.0
+			if state == metric.State {
+				value = 1
+			}
+			result = append(result, series(metric.Name, value, metric.Name, state))
+		}
+	default:
+		result = append(result, metric)
+	}
+	return result
+}
+
+// reports whether one of the metrics has the name, value and labels of the expected series
+func containsSeries(metrics []collector.Metric, name string, expected config.ExpectedSeries) bool {
+	for _, metric := range metrics {
+		if metric.Name == name && equalValues(metric.Value, expected.Value) && hasLabels(metric.Labels, expected.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// compares values allowing for floating point rounding, e.g. from multipliers
+func equalValues(got, expected float64) bool {
+	if math.IsNaN(got) || math.IsNaN(expected) {
+		return math.IsNaN(got) && math.IsNaN(expected)
+	}
+	return math.Abs(got-expected) <= 1e-9*math.Max(1, math.Abs(expected))
+}
+
+// reports whether labels contain all expected label values
+func hasLabels(labels, expected map[string]string) bool {
+	for k, v := range expected {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -2,23 +2,51 @@ package collector
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
 )
 
 type Metric struct {
-	Name   string
-	Value  float64
-	Type   string
-	Help   string
-	Labels map[string]string
+	Name      string            `json:"name"`
+	Value     float64           `json:"value"`
+	Type      string            `json:"type"`
+	Help      string            `json:"help"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Buckets   []Bucket          `json:"buckets,omitempty"`   // Cumulative bucket counts of a histogram
+	Quantiles []Quantile        `json:"quantiles,omitempty"` // Quantiles of a summary
+	Sum       float64           `json:"sum,omitempty"`       // Sum of observations of a histogram or summary
+	Count     uint64            `json:"count,omitempty"`     // Number of observations of a histogram or summary
+	States    []string          `json:"states,omitempty"`    // Possible states of a stateset
+	State     string            `json:"state,omitempty"`     // Current state of a stateset
+	Output    string            `json:"-"`                   // Output group the metric is written to
+}
+
+// histogram bucket counting observations less than or equal to UpperBound
+type Bucket struct {
+	UpperBound float64 `json:"upper_bound"`
+	Count      uint64  `json:"count"`
+}
+
+// summary quantile with its observed value
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
 }
 
 type CollectResult struct {
-	Metric      Metric // Metrics collected
-	MetricValid bool   // Metrics valid
-	Error       error  // Errors encountered
-	HasWarning  bool   // Are there any warnings, e.g., if default values are used
+	Metrics     []Metric      // Metrics collected
+	MetricValid bool          // Metrics valid
+	Error       error         // Errors encountered
+	HasWarning  bool          // Are there any warnings, e.g., if default values are used
+	DefaultUsed bool          // Whether a configured default value was used
+	KeptLast    bool          // Whether the value of the last successful collection was reused
+	Cached      bool          // Whether the result was reused from the cache without executing the command
+	ExitCode    int           // Exit code of the executed command
+	Duration    time.Duration // Time taken to execute the command
+	TimedOut    bool          // Whether the command was killed after the timeout
+	Output      string        // Raw command output
 }
 
 type Collector interface {
@@ -26,15 +54,53 @@ type Collector interface {
 	Collect() CollectResult
 }
 
-func NewCollector(metricConfig config.MetricConfig, timeoutSec int) (Collector, error) {
-	switch metricConfig.Collector.Type {
-	case "returncode":
-		return NewReturnCodeCollector(metricConfig, timeoutSec), nil
-	case "returncode_mapping":
-		return NewReturnCodeMappingCollector(metricConfig, timeoutSec)
-	case "output_parse":
-		return NewOutputParseCollector(metricConfig, timeoutSec)
-	default:
+// InputCollector is implemented by collectors that compute their value from
+// the metrics collected before them in the same run
+type InputCollector interface {
+	Collector
+	SetInputs(metrics []Metric)
+}
+
+// Tracer receives a description of each step a collector takes to turn
+// command output into a value
+type Tracer func(format string, args ...any)
+
+// calls the tracer if one is set
+func (t Tracer) printf(format string, args ...any) {
+	if t != nil {
+		t(format, args...)
+	}
+}
+
+// TraceableCollector is implemented by collectors that can describe how they
+// obtain their value, used by the test command
+type TraceableCollector interface {
+	Collector
+	SetTracer(tracer Tracer)
+}
+
+// creates the collector of a metric, executing its command with exec. A nil
+// executor executes commands with the shell.
+func NewCollector(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+	if exec == nil {
+		exec = executor.ShellExecutor{}
+	}
+
+	factory, ok := lookup(metricConfig.Collector.Type)
+	if !ok {
 		return nil, fmt.Errorf("unknown collector type: %s", metricConfig.Collector.Type)
 	}
+
+	return factory(metricConfig, timeoutSec, exec)
+}
+
+// reports whether the collector of a metric is an InputCollector, which must
+// be collected after the metrics it reads. Creating a collector executes nothing.
+func ReadsInputs(metricConfig config.MetricConfig) bool {
+	col, err := NewCollector(metricConfig, 1, nil)
+	if err != nil {
+		return false
+	}
+	_, ok := col.(InputCollector)
+	return ok
 }
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+func init() {
+	Register("derived", Registration{
+		New: func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+			return NewDerivedCollector(metricConfig)
+		},
+		Validate: config.ValidateDerivedCollector,
+	})
+}
+
+// collects metrics by computing a value from other metrics of the same run
+type DerivedCollector struct {
+	metricConfig config.MetricConfig
+	inputs       []Metric
+}
+
+// creates a new DerivedCollector
+func NewDerivedCollector(metricConfig config.MetricConfig) (*DerivedCollector, error) {
+	if metricConfig.Collector.Derived == nil {
+		return nil, fmt.Errorf("derived collector requires derived configuration")
+	}
+
+	return &DerivedCollector{
+		metricConfig: metricConfig,
+	}, nil
+}
+
+// sets the metrics collected so far in the run
+func (c *DerivedCollector) SetInputs(metrics []Metric) {
+	c.inputs = metrics
+}
+
+// computes the metric value from the selected input series
+func (c *DerivedCollector) Collect() CollectResult {
+	derived := c.metricConfig.Collector.Derived
+
+	result := CollectResult{
+		MetricValid: false,
+	}
+
+	metric := Metric{
+		Name:   c.metricConfig.Name,
+		Type:   c.metricConfig.Type,
+		Help:   c.metricConfig.Help,
+		Labels: c.metricConfig.Collector.Labels,
+	}
+
+	values := selectValues(c.inputs, derived.Selector)
+
+	var value float64
+	switch derived.Function {
+	case "count", "sum":
+		// A clean 0 would hide a selector that matches nothing
+		if len(values) == 0 {
+			result.Error = fmt.Errorf("no series of %s matched", derived.Metric)
+			result.HasWarning = true
+		}
+		value = sum(values)
+		if derived.Function == "count" {
+			value = float64(len(values))
+		}
+	case "min", "max", "avg":
+		if len(values) == 0 {
+			result.Error = fmt.Errorf("no series of %s matched", derived.Metric)
+			return result
+		}
+		value = aggregate(derived.Function, values)
+	case "ratio":
+		denominator := sum(selectValues(c.inputs, *derived.Denominator))
+		if denominator == 0 {
+			result.Error = fmt.Errorf("denominator %s is zero or has no matching series", derived.Denominator.Metric)
+			return result
+		}
+		value = sum(values) / denominator
+	default:
+		result.Error = fmt.Errorf("unknown derived function: %s", derived.Function)
+		return result
+	}
+
+	metric.Value = value
+	result.Metrics = []Metric{metric}
+	result.MetricValid = true
+
+	return result
+}
+
+// returns the values of the series matching the selector. Series of types
+// without a single value, such as histograms, are ignored.
+func selectValues(metrics []Metric, selector config.Selector) []float64 {
+	var values []float64
+	for _, metric := range metrics {
+		if metric.Name != selector.Metric {
+			continue
+		}
+		switch metric.Type {
+		case "histogram", "summary", "stateset":
+			continue
+		}
+		if !matchLabels(metric.Labels, selector.Match) {
+			continue
+		}
+		values = append(values, metric.Value)
+	}
+	return values
+}
+
+// reports whether labels contain all label values of match
+func matchLabels(labels, match map[string]string) bool {
+	for k, v := range match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// computes min, max or avg of a non-empty list of values
+func aggregate(function string, values []float64) float64 {
+	switch function {
+	case "min":
+		result := math.Inf(1)
+		for _, v := range values {
+			result = math.Min(result, v)
+		}
+		return result
+	case "max":
+		result := math.Inf(-1)
+		for _, v := range values {
+			result = math.Max(result, v)
+		}
+		return result
+	default:
+		return sum(values) / float64(len(values))
+	}
+}
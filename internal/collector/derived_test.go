@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+)
+
+func TestDerivedCollect(t *testing.T) {
+	inputs := []Metric{
+		{Name: "up", Type: "gauge", Value: 1, Labels: map[string]string{"host": "a"}},
+		{Name: "up", Type: "gauge", Value: 0, Labels: map[string]string{"host": "b"}},
+		{Name: "up", Type: "gauge", Value: 1, Labels: map[string]string{"host": "c"}},
+		{Name: "latency", Type: "histogram", Value: 5},
+	}
+
+	tests := []struct {
+		function    string
+		metric      string
+		match       map[string]string
+		denominator *config.Selector
+		want        float64
+		warning     string
+	}{
+		{function: "sum", metric: "up", want: 2},
+		{function: "count", metric: "up", want: 3},
+		{function: "min", metric: "up", want: 0},
+		{function: "max", metric: "up", want: 1},
+		{function: "avg", metric: "up", match: map[string]string{"host": "a"}, want: 1},
+		{function: "ratio", metric: "up", denominator: &config.Selector{Metric: "up", Match: map[string]string{"host": "a"}}, want: 2},
+		{function: "sum", metric: "down", want: 0, warning: "no series of down matched"},
+		{function: "count", metric: "up", match: map[string]string{"host": "z"}, want: 0, warning: "no series of up matched"},
+		{function: "count", metric: "latency", want: 0, warning: "no series of latency matched"},
+	}
+
+	for _, tt := range tests {
+		metricConfig := config.MetricConfig{
+			Name: "derived",
+			Type: "gauge",
+			Collector: config.CollectorConfig{
+				Type: "derived",
+				Derived: &config.DerivedConfig{
+					Function:    tt.function,
+					Selector:    config.Selector{Metric: tt.metric, Match: tt.match},
+					Denominator: tt.denominator,
+				},
+			},
+		}
+		c, err := NewDerivedCollector(metricConfig)
+		if err != nil {
+			t.Fatalf("NewDerivedCollector() error = %v", err)
+		}
+		c.SetInputs(inputs)
+
+		result := c.Collect()
+		if !result.MetricValid {
+			t.Errorf("%s of %s: Collect() error = %v", tt.function, tt.metric, result.Error)
+			continue
+		}
+		if got := result.Metrics[0].Value; got != tt.want {
+			t.Errorf("%s of %s = %g, want %g", tt.function, tt.metric, got, tt.want)
+		}
+		switch {
+		case tt.warning == "" && result.Error != nil:
+			t.Errorf("%s of %s: warning = %v, want none", tt.function, tt.metric, result.Error)
+		case tt.warning != "" && (result.Error == nil || !result.HasWarning || !strings.Contains(result.Error.Error(), tt.warning)):
+			t.Errorf("%s of %s: warning = %v, want %q", tt.function, tt.metric, result.Error, tt.warning)
+		}
+	}
+}
+
+func TestDerivedCollectErrors(t *testing.T) {
+	tests := []struct {
+		derived config.DerivedConfig
+		want    string
+	}{
+		{config.DerivedConfig{Function: "min", Selector: config.Selector{Metric: "down"}}, "no series of down matched"},
+		{config.DerivedConfig{Function: "ratio", Selector: config.Selector{Metric: "up"}, Denominator: &config.Selector{Metric: "down"}}, "denominator down is zero"},
+	}
+
+	for _, tt := range tests {
+		c, err := NewDerivedCollector(config.MetricConfig{Name: "derived", Type: "gauge", Collector: config.CollectorConfig{Type: "derived", Derived: &tt.derived}})
+		if err != nil {
+			t.Fatalf("NewDerivedCollector() error = %v", err)
+		}
+		c.SetInputs([]Metric{{Name: "up", Type: "gauge", Value: 1}})
+
+		result := c.Collect()
+		if result.MetricValid || result.Error == nil || !strings.Contains(result.Error.Error(), tt.want) {
+			t.Errorf("%s: Collect() = %v, %v, want error %q", tt.derived.Function, result.Metrics, result.Error, tt.want)
+		}
+	}
+}
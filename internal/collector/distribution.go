@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+// quantiles of a summary without configured quantiles
+var defaultQuantiles = []float64{0.5, 0.9, 0.99}
+
+// parses every match of the pattern in the command output as an observation
+// and builds a histogram or summary from them
+func (c *OutputParseCollector) collectDistribution(cmdResult executor.ExecuteCommandResult, result CollectResult, metric Metric) CollectResult {
+	parse := c.metricConfig.Collector.Parse
+
+	if cmdResult.Error != nil {
+		result.Error = fmt.Errorf("command execution failed: %w", cmdResult.Error)
+		return result
+	}
+
+	re, err := regexp.Compile(parse.Pattern)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid regex pattern: %w", err)
+		return result
+	}
+
+	observations := []float64{}
+	for _, matches := range re.FindAllStringSubmatch(cmdResult.Output, -1) {
+		if len(matches) <= parse.Index {
+			result.Error = fmt.Errorf("index out of range")
+			return result
+		}
+
+		// default_value is rejected for distributions, so no default is used
+		var value float64
+		if parse.Expression != "" {
+			value, _, err = evaluateExpression(re, matches, parse, c.tracer)
+		} else {
+			value, _, err = convertValue(matches[parse.Index], parse, c.tracer)
+		}
+		if err != nil {
+			result.Error = fmt.Errorf("could not parse observation: %w", err)
+			return result
+		}
+
+		observations = append(observations, value)
+	}
+
+	sort.Float64s(observations)
+
+	metric.Count = uint64(len(observations))
+	for _, value := range observations {
+		metric.Sum += value
+	}
+
+	switch c.metricConfig.Type {
+	case "histogram":
+		metric.Buckets = histogramBuckets(observations, c.metricConfig.Buckets)
+	case "summary":
+		quantiles := c.metricConfig.Quantiles
+		if len(quantiles) == 0 {
+			quantiles = defaultQuantiles
+		}
+		metric.Quantiles = summaryQuantiles(observations, quantiles)
+	}
+
+	result.Metrics = []Metric{metric}
+	result.MetricValid = true
+
+	return result
+}
+
+// counts sorted observations into cumulative buckets
+func histogramBuckets(observations []float64, upperBounds []float64) []Bucket {
+	buckets := make([]Bucket, 0, len(upperBounds))
+	for _, upperBound := range upperBounds {
+		count := sort.Search(len(observations), func(i int) bool {
+			return observations[i] > upperBound
+		})
+		buckets = append(buckets, Bucket{UpperBound: upperBound, Count: uint64(count)})
+	}
+	return buckets
+}
+
+// computes quantiles of sorted observations using linear interpolation between
+// the closest ranks. Quantiles of no observations are NaN.
+func summaryQuantiles(observations []float64, quantiles []float64) []Quantile {
+	result := make([]Quantile, 0, len(quantiles))
+	for _, q := range quantiles {
+		value := math.NaN()
+		if len(observations) > 0 {
+			pos := q * float64(len(observations)-1)
+			lower := int(math.Floor(pos))
+			upper := int(math.Ceil(pos))
+			value = observations[lower] + (observations[upper]-observations[lower])*(pos-float64(lower))
+		}
+		result = append(result, Quantile{Quantile: q, Value: value})
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// JSONFloat is a float64 that encodes the non-finite values NaN, +Inf and
+// -Inf, which JSON numbers cannot represent, as the strings "NaN", "+Inf" and
+// "-Inf". A summary without observations has NaN quantiles and a histogram
+// always has a +Inf bucket, so metrics must survive a round trip through JSON.
+type JSONFloat float64
+
+func (f JSONFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return json.Marshal(v)
+}
+
+func (f *JSONFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		switch s {
+		case "NaN", "+Inf", "-Inf":
+			v, _ := strconv.ParseFloat(s, 64)
+			*f = JSONFloat(v)
+			return nil
+		}
+		return fmt.Errorf("invalid number %q", s)
+	}
+
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = JSONFloat(v)
+	return nil
+}
+
+func (m Metric) MarshalJSON() ([]byte, error) {
+	type plain Metric
+	return json.Marshal(struct {
+		plain
+		Value JSONFloat `json:"value"`
+		Sum   JSONFloat `json:"sum,omitempty"`
+	}{plain(m), JSONFloat(m.Value), JSONFloat(m.Sum)})
+}
+
+func (m *Metric) UnmarshalJSON(data []byte) error {
+	type plain Metric
+	aux := struct {
+		*plain
+		Value JSONFloat `json:"value"`
+		Sum   JSONFloat `json:"sum,omitempty"`
+	}{plain: (*plain)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	m.Value = float64(aux.Value)
+	m.Sum = float64(aux.Sum)
+	return nil
+}
+
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		UpperBound JSONFloat `json:"upper_bound"`
+		Count      uint64    `json:"count"`
+	}{JSONFloat(b.UpperBound), b.Count})
+}
+
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		UpperBound JSONFloat `json:"upper_bound"`
+		Count      uint64    `json:"count"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*b = Bucket{UpperBound: float64(aux.UpperBound), Count: aux.Count}
+	return nil
+}
+
+func (q Quantile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Quantile JSONFloat `json:"quantile"`
+		Value    JSONFloat `json:"value"`
+	}{JSONFloat(q.Quantile), JSONFloat(q.Value)})
+}
+
+func (q *Quantile) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Quantile JSONFloat `json:"quantile"`
+		Value    JSONFloat `json:"value"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*q = Quantile{Quantile: float64(aux.Quantile), Value: float64(aux.Value)}
+	return nil
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestMetricJSONNonFinite(t *testing.T) {
+	metric := Metric{
+		Name:      "latency_seconds",
+		Value:     math.NaN(),
+		Type:      "summary",
+		Buckets:   []Bucket{{UpperBound: 1, Count: 2}, {UpperBound: math.Inf(1), Count: 3}},
+		Quantiles: []Quantile{{Quantile: 0.5, Value: math.NaN()}},
+		Sum:       math.Inf(-1),
+	}
+
+	data, err := json.Marshal(metric)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var decoded Metric
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", data, err)
+	}
+
+	if !math.IsNaN(decoded.Value) {
+		t.Errorf("Value = %v, want NaN", decoded.Value)
+	}
+	if !math.IsInf(decoded.Sum, -1) {
+		t.Errorf("Sum = %v, want -Inf", decoded.Sum)
+	}
+	if len(decoded.Buckets) != 2 || !math.IsInf(decoded.Buckets[1].UpperBound, 1) || decoded.Buckets[1].Count != 3 {
+		t.Errorf("Buckets = %v, want +Inf bucket with count 3", decoded.Buckets)
+	}
+	if len(decoded.Quantiles) != 1 || decoded.Quantiles[0].Quantile != 0.5 || !math.IsNaN(decoded.Quantiles[0].Value) {
+		t.Errorf("Quantiles = %v, want 0.5 quantile with NaN value", decoded.Quantiles)
+	}
+	if decoded.Name != metric.Name || decoded.Type != metric.Type {
+		t.Errorf("decoded = %+v, want name and type of %+v", decoded, metric)
+	}
+}
+
+func TestJSONFloatFinite(t *testing.T) {
+	data, err := json.Marshal(JSONFloat(1.5))
+	if err != nil || string(data) != "1.5" {
+		t.Errorf("Marshal(1.5) = %s, %v, want 1.5", data, err)
+	}
+
+	var f JSONFloat
+	if err := json.Unmarshal([]byte(`"1.5"`), &f); err == nil {
+		t.Errorf("Unmarshal(\"1.5\") = %v, want error for a number given as string", f)
+	}
+}
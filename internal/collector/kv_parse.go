@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+func init() {
+	Register("kv_parse", Registration{
+		New: func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+			return NewKVParseCollector(metricConfig, timeoutSec, exec)
+		},
+		Validate: config.ValidateKVParseCollector,
+	})
+}
+
+// collects metrics from "key: value" or "key=value" lines of command output
+type KVParseCollector struct {
+	metricConfig config.MetricConfig
+	timeoutSec   int
+	executor     executor.Executor
+}
+
+// creates a new KVParseCollector
+func NewKVParseCollector(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (*KVParseCollector, error) {
+	if metricConfig.Collector.KV == nil {
+		return nil, fmt.Errorf("kv_parse collector requires kv configuration")
+	}
+
+	return &KVParseCollector{
+		metricConfig: metricConfig,
+		timeoutSec:   timeoutSec,
+		executor:     exec,
+	}, nil
+}
+
+// executes the command and turns each key into a metric or label value
+func (c *KVParseCollector) Collect() CollectResult {
+	collector := c.metricConfig.Collector
+	kv := collector.KV
+
+	cmdResult := c.executor.Execute(
+		collector.Command,
+		c.timeoutSec,
+	)
+
+	result := CollectResult{
+		MetricValid: false,
+		ExitCode:    cmdResult.ExitCode,
+		Duration:    cmdResult.Duration,
+		TimedOut:    cmdResult.TimedOut,
+		Output:      cmdResult.Output,
+	}
+
+	if cmdResult.Error != nil {
+		result.Error = fmt.Errorf("command execution failed: %w", cmdResult.Error)
+		return result
+	}
+
+	var valuePattern *regexp.Regexp
+	if kv.ValuePattern != "" {
+		var err error
+		valuePattern, err = regexp.Compile(kv.ValuePattern)
+		if err != nil {
+			result.Error = fmt.Errorf("invalid value_pattern: %w", err)
+			return result
+		}
+	}
+
+	parse := &config.ParseConfig{
+		ValueType:  kv.ValueType,
+		TimeLayout: kv.TimeLayout,
+	}
+
+	keyLabel := kv.KeyLabel
+	if keyLabel == "" {
+		keyLabel = "key"
+	}
+
+	// Keys found, and the key of each series emitted. In metric mode different
+	// keys such as "Active Devices" and "active-devices" become the same series.
+	seen := make(map[string]bool)
+	series := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(cmdResult.Output))
+	for scanner.Scan() {
+		key, rawValue, ok := splitKV(scanner.Text(), kv.Separator)
+		if !ok || seen[key] {
+			continue
+		}
+		if len(kv.Keys) > 0 && !slices.Contains(kv.Keys, key) {
+			continue
+		}
+
+		seriesKey := key
+		if kv.Mode != "label" {
+			seriesKey = config.SanitizeName(key)
+		}
+		if first, ok := series[seriesKey]; ok {
+			if len(kv.Keys) > 0 {
+				result.Error = fmt.Errorf("keys '%s' and '%s' both become %s_%s", first, key, c.metricConfig.Name, seriesKey)
+				return result
+			}
+			continue
+		}
+
+		if valuePattern != nil {
+			matches := valuePattern.FindStringSubmatch(rawValue)
+			if matches == nil {
+				continue
+			}
+			rawValue = matches[1]
+		}
+
+		value, err := parseNumber(rawValue, parse)
+		if err != nil {
+			// Without explicit keys, values that are not numbers are expected and skipped
+			if len(kv.Keys) > 0 {
+				result.Error = fmt.Errorf("key '%s': %w", key, err)
+				return result
+			}
+			continue
+		}
+		if kv.Multiplier != 0 {
+			value *= kv.Multiplier
+		}
+		seen[key] = true
+		series[seriesKey] = key
+
+		metric := Metric{
+			Name:   c.metricConfig.Name,
+			Value:  value,
+			Type:   c.metricConfig.Type,
+			Help:   c.metricConfig.Help,
+			Labels: collector.Labels,
+		}
+		if kv.Mode == "label" {
+			metric.Labels = withLabels(collector.Labels, map[string]string{keyLabel: key})
+		} else {
+			metric.Name = c.metricConfig.Name + "_" + seriesKey
+		}
+		result.Metrics = append(result.Metrics, metric)
+	}
+
+	for _, key := range kv.Keys {
+		if !seen[key] {
+			result.Error = fmt.Errorf("key '%s' not found in output", key)
+			return result
+		}
+	}
+
+	if len(result.Metrics) == 0 {
+		result.Error = fmt.Errorf("no numeric key-value pairs found in output")
+		return result
+	}
+
+	result.MetricValid = true
+	return result
+}
+
+// splits a line at the separator, or at the first ":" or "=" if none is configured
+func splitKV(line, separator string) (string, string, bool) {
+	index := strings.IndexAny(line, ":=")
+	separatorLen := 1
+	if separator != "" {
+		index = strings.Index(line, separator)
+		separatorLen = len(separator)
+	}
+	if index == -1 {
+		return "", "", false
+	}
+
+	key := strings.TrimSpace(line[:index])
+	value := strings.TrimSpace(line[index+separatorLen:])
+	if key == "" {
+		return "", "", false
+	}
+
+	return key, value, true
+}
+
+// returns a copy of labels with additional labels
+func withLabels(labels map[string]string, extra map[string]string) map[string]string {
+	result := make(map[string]string, len(labels)+len(extra))
+	for k, v := range labels {
+		result[k] = v
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+func collectKV(t *testing.T, kv config.KVParseConfig, output string) CollectResult {
+	t.Helper()
+	metricConfig := config.MetricConfig{
+		Name: "mdadm",
+		Type: "gauge",
+		Collector: config.CollectorConfig{
+			Type:    "kv_parse",
+			Command: "mdadm --detail /dev/md0",
+			KV:      &kv,
+		},
+	}
+	c, err := NewKVParseCollector(metricConfig, 1, executor.FixtureExecutor{Output: output})
+	if err != nil {
+		t.Fatalf("NewKVParseCollector() error = %v", err)
+	}
+	return c.Collect()
+}
+
+func TestKVParseCollect(t *testing.T) {
+	tests := []struct {
+		name   string
+		kv     config.KVParseConfig
+		output string
+		want   map[string]float64 // series name, or key label value in label mode, to value
+	}{
+		{
+			name:   "metric mode",
+			output: "Raid Level : raid1\nActive Devices : 2\nFailed Devices : 0\n",
+			want:   map[string]float64{"mdadm_active_devices": 2, "mdadm_failed_devices": 0},
+		},
+		{
+			name:   "keys that become the same series",
+			output: "Active Devices : 2\nactive-devices= 3\n",
+			want:   map[string]float64{"mdadm_active_devices": 2},
+		},
+		{
+			name:   "repeated key",
+			output: "used=1\nused=2\n",
+			want:   map[string]float64{"mdadm_used": 1},
+		},
+		{
+			name:   "label mode keeps keys that differ",
+			kv:     config.KVParseConfig{Mode: "label"},
+			output: "Active Devices : 2\nactive-devices= 3\n",
+			want:   map[string]float64{"Active Devices": 2, "active-devices": 3},
+		},
+		{
+			name:   "separator, keys, value pattern and multiplier",
+			kv:     config.KVParseConfig{Separator: "->", Keys: []string{"temp"}, ValuePattern: `^\+?([0-9.]+)`, Multiplier: 2},
+			output: "temp -> +45.5°C\nfan -> 1200\n",
+			want:   map[string]float64{"mdadm_temp": 91},
+		},
+		{
+			name:   "value type",
+			kv:     config.KVParseConfig{ValueType: "bytes"},
+			output: "size: 2K\n",
+			want:   map[string]float64{"mdadm_size": 2048},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := collectKV(t, tt.kv, tt.output)
+			if !result.MetricValid {
+				t.Fatalf("Collect() error = %v", result.Error)
+			}
+
+			got := make(map[string]float64)
+			for _, metric := range result.Metrics {
+				id := metric.Name
+				if tt.kv.Mode == "label" {
+					id = metric.Labels["key"]
+				}
+				if _, ok := got[id]; ok {
+					t.Errorf("Collect() emitted %s twice", id)
+				}
+				got[id] = metric.Value
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Collect() = %v, want %v", got, tt.want)
+			}
+			for id, value := range tt.want {
+				if got[id] != value {
+					t.Errorf("Collect() %s = %g, want %g", id, got[id], value)
+				}
+			}
+		})
+	}
+}
+
+func TestKVParseCollectErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		kv     config.KVParseConfig
+		output string
+		want   string
+	}{
+		{"no numeric values", config.KVParseConfig{}, "state: clean\n", "no numeric key-value pairs"},
+		{"missing key", config.KVParseConfig{Keys: []string{"used", "free"}}, "used: 1\n", "key 'free' not found"},
+		{"key that is not a number", config.KVParseConfig{Keys: []string{"state"}}, "state: clean\n", "key 'state'"},
+		{"listed keys that become the same series", config.KVParseConfig{Keys: []string{"Active Devices", "active-devices"}}, "Active Devices : 2\nactive-devices= 3\n", "both become mdadm_active_devices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := collectKV(t, tt.kv, tt.output)
+			if result.MetricValid {
+				t.Fatalf("Collect() = %v, want error", result.Metrics)
+			}
+			if result.Error == nil || !strings.Contains(result.Error.Error(), tt.want) {
+				t.Errorf("Collect() error = %v, want %q", result.Error, tt.want)
+			}
+		})
+	}
+}
@@ -3,19 +3,31 @@ package collector
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/zinrai/prom-textfile-exporter/internal/config"
 	"github.com/zinrai/prom-textfile-exporter/internal/executor"
 )
 
+func init() {
+	Register("output_parse", Registration{
+		New: func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+			return NewOutputParseCollector(metricConfig, timeoutSec, exec)
+		},
+		Validate: config.ValidateOutputParseCollector,
+	})
+}
+
 // collects metrics by parsing command output
 type OutputParseCollector struct {
 	metricConfig config.MetricConfig
 	timeoutSec   int
+	executor     executor.Executor
+	tracer       Tracer
 }
 
 // creates a new OutputParseCollector
-func NewOutputParseCollector(metricConfig config.MetricConfig, timeoutSec int) (*OutputParseCollector, error) {
+func NewOutputParseCollector(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (*OutputParseCollector, error) {
 	// Validate parse configuration
 	if metricConfig.Collector.Parse == nil {
 		return nil, fmt.Errorf("output_parse collector requires parse configuration")
@@ -24,9 +36,15 @@ func NewOutputParseCollector(metricConfig config.MetricConfig, timeoutSec int) (
 	return &OutputParseCollector{
 		metricConfig: metricConfig,
 		timeoutSec:   timeoutSec,
+		executor:     exec,
 	}, nil
 }
 
+// sets the tracer receiving the parse steps
+func (c *OutputParseCollector) SetTracer(tracer Tracer) {
+	c.tracer = tracer
+}
+
 // executes the command and parses its output for the metric value
 func (c *OutputParseCollector) Collect() CollectResult {
 	collector := c.metricConfig.Collector
@@ -43,18 +61,33 @@ func (c *OutputParseCollector) Collect() CollectResult {
 		Labels: collector.Labels,
 	}
 
-	cmdResult := executor.ExecuteCommandWithResult(
+	cmdResult := c.executor.Execute(
 		collector.Command,
 		c.timeoutSec,
 	)
+	result.ExitCode = cmdResult.ExitCode
+	result.Duration = cmdResult.Duration
+	result.TimedOut = cmdResult.TimedOut
+	result.Output = cmdResult.Output
+
+	// Metric types that are not built from a single extracted value
+	switch c.metricConfig.Type {
+	case "histogram", "summary":
+		return c.collectDistribution(cmdResult, result, metric)
+	case "info":
+		return c.collectInfo(cmdResult, result, metric)
+	case "stateset":
+		return c.collectStateSet(cmdResult, result, metric)
+	}
 
 	if cmdResult.Error != nil {
 		// If default values are set
 		if parse.DefaultValue != nil {
 			metric.Value = *parse.DefaultValue
-			result.Metric = metric
+			result.Metrics = []Metric{metric}
 			result.MetricValid = true
 			result.HasWarning = true
+			result.DefaultUsed = true
 			result.Error = fmt.Errorf("command execution failed (using default value): %w", cmdResult.Error)
 			return result
 		}
@@ -64,13 +97,20 @@ func (c *OutputParseCollector) Collect() CollectResult {
 		return result
 	}
 
+	// Counting modes do not extract a value, and empty output simply counts zero
+	switch parse.Mode {
+	case "line_count", "match_count", "match_exists":
+		return c.collectCount(cmdResult, result, metric)
+	}
+
 	output := cmdResult.Output
 	if output == "" {
 		if parse.DefaultValue != nil {
 			metric.Value = *parse.DefaultValue
-			result.Metric = metric
+			result.Metrics = []Metric{metric}
 			result.MetricValid = true
 			result.HasWarning = true
+			result.DefaultUsed = true
 			result.Error = fmt.Errorf("empty command output (using default value)")
 			return result
 		}
@@ -83,9 +123,10 @@ func (c *OutputParseCollector) Collect() CollectResult {
 	if err != nil {
 		if parse.DefaultValue != nil {
 			metric.Value = *parse.DefaultValue
-			result.Metric = metric
+			result.Metrics = []Metric{metric}
 			result.MetricValid = true
 			result.HasWarning = true
+			result.DefaultUsed = true
 			result.Error = fmt.Errorf("invalid regex pattern (using default value): %w", err)
 			return result
 		}
@@ -95,12 +136,14 @@ func (c *OutputParseCollector) Collect() CollectResult {
 	}
 
 	matches := re.FindStringSubmatch(output)
+	c.traceMatch(re, matches)
 	if len(matches) <= parse.Index {
 		if parse.DefaultValue != nil {
 			metric.Value = *parse.DefaultValue
-			result.Metric = metric
+			result.Metrics = []Metric{metric}
 			result.MetricValid = true
 			result.HasWarning = true
+			result.DefaultUsed = true
 			result.Error = fmt.Errorf("pattern didn't match or index out of range (using default value)")
 			return result
 		}
@@ -111,13 +154,21 @@ func (c *OutputParseCollector) Collect() CollectResult {
 
 	// Value Extraction and Conversion
 	extractedStr := matches[parse.Index]
-	value, err := convertValue(extractedStr, parse)
+	var value float64
+	var defaultUsed bool
+	if parse.Expression != "" {
+		value, defaultUsed, err = evaluateExpression(re, matches, parse, c.tracer)
+	} else {
+		c.tracer.printf("index %d: %q", parse.Index, extractedStr)
+		value, defaultUsed, err = convertValue(extractedStr, parse, c.tracer)
+	}
 	if err != nil {
 		if parse.DefaultValue != nil {
 			metric.Value = *parse.DefaultValue
-			result.Metric = metric
+			result.Metrics = []Metric{metric}
 			result.MetricValid = true
 			result.HasWarning = true
+			result.DefaultUsed = true
 			result.Error = fmt.Errorf("could not parse value (using default): %w", err)
 			return result
 		}
@@ -128,8 +179,79 @@ func (c *OutputParseCollector) Collect() CollectResult {
 
 	// If the value is successfully obtained
 	metric.Value = value
-	result.Metric = metric
+	result.Metrics = []Metric{metric}
+	result.MetricValid = true
+	if defaultUsed {
+		result.HasWarning = true
+		result.DefaultUsed = true
+		result.Error = fmt.Errorf("string '%s' not found in mapping (using default value)", extractedStr)
+	}
+
+	return result
+}
+
+// counts lines or matches of the pattern in the command output
+func (c *OutputParseCollector) collectCount(cmdResult executor.ExecuteCommandResult, result CollectResult, metric Metric) CollectResult {
+	parse := c.metricConfig.Collector.Parse
+
+	var re *regexp.Regexp
+	if parse.Pattern != "" {
+		var err error
+		re, err = regexp.Compile(parse.Pattern)
+		if err != nil {
+			result.Error = fmt.Errorf("invalid regex pattern: %w", err)
+			return result
+		}
+	}
+
+	var count int
+	switch parse.Mode {
+	case "line_count":
+		// Count non-empty lines, or only those matching the pattern if one is given
+		for _, line := range strings.Split(cmdResult.Output, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if re == nil || re.MatchString(line) {
+				count++
+			}
+		}
+	case "match_count":
+		count = len(re.FindAllStringIndex(cmdResult.Output, -1))
+	case "match_exists":
+		if re.MatchString(cmdResult.Output) {
+			count = 1
+		}
+	}
+
+	c.tracer.printf("%s: %d", parse.Mode, count)
+
+	metric.Value = float64(count)
+	if parse.Multiplier != 0 {
+		metric.Value *= parse.Multiplier
+		c.tracer.printf("multiplier %g: -> %g", parse.Multiplier, metric.Value)
+	}
+	result.Metrics = []Metric{metric}
 	result.MetricValid = true
 
 	return result
 }
+
+// traces the groups of a match, or that the pattern did not match
+func (c *OutputParseCollector) traceMatch(re *regexp.Regexp, matches []string) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.printf("pattern: %s", re)
+	if matches == nil {
+		c.tracer.printf("pattern did not match")
+		return
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			c.tracer.printf("group %d (%s): %q", i, name, matches[i])
+		} else {
+			c.tracer.printf("group %d: %q", i, matches[i])
+		}
+	}
+}
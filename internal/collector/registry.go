@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+// Factory creates the collector of a metric, executing its command with exec
+type Factory func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error)
+
+// Registration describes a collector type
+type Registration struct {
+	New      Factory                   // Creates the collector of a metric
+	Validate config.CollectorValidator // Validates the collector configuration when loading it, may be nil
+}
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// registers a collector type with its constructor and configuration validator,
+// so that configurations may use it. Registering a type twice, or without a
+// constructor, panics.
+func Register(collectorType string, registration Registration) {
+	if registration.New == nil {
+		panic(fmt.Sprintf("collector type %s registered without a constructor", collectorType))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := factories[collectorType]; ok {
+		panic(fmt.Sprintf("collector type %s registered twice", collectorType))
+	}
+	factories[collectorType] = registration.New
+	config.RegisterCollectorType(collectorType, registration.Validate)
+}
+
+// returns the sorted names of the registered collector types
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for t := range factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// returns the constructor of a collector type
+func lookup(collectorType string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, ok := factories[collectorType]
+	return factory, ok
+}
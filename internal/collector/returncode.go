@@ -5,17 +5,28 @@ import (
 	"github.com/zinrai/prom-textfile-exporter/internal/executor"
 )
 
+func init() {
+	Register("returncode", Registration{
+		New: func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+			return NewReturnCodeCollector(metricConfig, timeoutSec, exec), nil
+		},
+		Validate: config.ValidateReturnCodeCollector,
+	})
+}
+
 // collects metrics based on command return codes
 type ReturnCodeCollector struct {
 	metricConfig config.MetricConfig
 	timeoutSec   int
+	executor     executor.Executor
 }
 
 // creates a new ReturnCodeCollector
-func NewReturnCodeCollector(metricConfig config.MetricConfig, timeoutSec int) *ReturnCodeCollector {
+func NewReturnCodeCollector(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) *ReturnCodeCollector {
 	return &ReturnCodeCollector{
 		metricConfig: metricConfig,
 		timeoutSec:   timeoutSec,
+		executor:     exec,
 	}
 }
 
@@ -24,7 +35,7 @@ func (c *ReturnCodeCollector) Collect() CollectResult {
 	collector := c.metricConfig.Collector
 
 	// Execute command
-	result := executor.ExecuteCommandWithResult(
+	result := c.executor.Execute(
 		collector.Command,
 		c.timeoutSec,
 	)
@@ -40,9 +51,13 @@ func (c *ReturnCodeCollector) Collect() CollectResult {
 
 	// For returncode collectors, metrics are always valid regardless of errors
 	return CollectResult{
-		Metric:      metric,
+		Metrics:     []Metric{metric},
 		MetricValid: true,
 		Error:       result.Error,
 		HasWarning:  result.Error != nil,
+		ExitCode:    result.ExitCode,
+		Duration:    result.Duration,
+		TimedOut:    result.TimedOut,
+		Output:      result.Output,
 	}
 }
@@ -8,16 +8,26 @@ import (
 	"github.com/zinrai/prom-textfile-exporter/internal/executor"
 )
 
+func init() {
+	Register("returncode_mapping", Registration{
+		New: func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+			return NewReturnCodeMappingCollector(metricConfig, timeoutSec, exec)
+		},
+		Validate: config.ValidateReturnCodeMappingCollector,
+	})
+}
+
 // collects metrics by mapping command return codes to values
 type ReturnCodeMappingCollector struct {
 	metricConfig config.MetricConfig
 	mapping      map[int]float64
 	defaultValue float64
 	timeoutSec   int
+	executor     executor.Executor
 }
 
 // creates a new ReturnCodeMappingCollector
-func NewReturnCodeMappingCollector(metricConfig config.MetricConfig, timeoutSec int) (*ReturnCodeMappingCollector, error) {
+func NewReturnCodeMappingCollector(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (*ReturnCodeMappingCollector, error) {
 	// Convert string keys to int keys for easier lookup
 	mapping := make(map[int]float64)
 	var defaultValue float64
@@ -46,6 +56,7 @@ func NewReturnCodeMappingCollector(metricConfig config.MetricConfig, timeoutSec
 		mapping:      mapping,
 		defaultValue: defaultValue,
 		timeoutSec:   timeoutSec,
+		executor:     exec,
 	}, nil
 }
 
@@ -54,7 +65,7 @@ func (c *ReturnCodeMappingCollector) Collect() CollectResult {
 	collector := c.metricConfig.Collector
 
 	// Execute command
-	result := executor.ExecuteCommandWithResult(
+	result := c.executor.Execute(
 		collector.Command,
 		c.timeoutSec,
 	)
@@ -77,9 +88,13 @@ func (c *ReturnCodeMappingCollector) Collect() CollectResult {
 
 	// For collectors, metrics are always valid regardless of errors
 	return CollectResult{
-		Metric:      metric,
+		Metrics:     []Metric{metric},
 		MetricValid: true,
 		Error:       result.Error,
 		HasWarning:  result.Error != nil,
+		ExitCode:    result.ExitCode,
+		Duration:    result.Duration,
+		TimedOut:    result.TimedOut,
+		Output:      result.Output,
 	}
 }
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+// builds an info metric whose value is always 1 and whose labels are taken
+// from the named capture groups of the pattern
+func (c *OutputParseCollector) collectInfo(cmdResult executor.ExecuteCommandResult, result CollectResult, metric Metric) CollectResult {
+	parse := c.metricConfig.Collector.Parse
+
+	if cmdResult.Error != nil {
+		result.Error = fmt.Errorf("command execution failed: %w", cmdResult.Error)
+		return result
+	}
+
+	re, err := regexp.Compile(parse.Pattern)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid regex pattern: %w", err)
+		return result
+	}
+
+	matches := re.FindStringSubmatch(cmdResult.Output)
+	c.traceMatch(re, matches)
+	if matches == nil {
+		result.Error = fmt.Errorf("pattern didn't match")
+		return result
+	}
+
+	labels := make(map[string]string, len(metric.Labels))
+	for k, v := range metric.Labels {
+		labels[k] = v
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			labels[name] = matches[i]
+		}
+	}
+
+	metric.Labels = labels
+	metric.Value = 1
+	result.Metrics = []Metric{metric}
+	result.MetricValid = true
+
+	return result
+}
+
+// builds a stateset metric from the state extracted from the command output
+func (c *OutputParseCollector) collectStateSet(cmdResult executor.ExecuteCommandResult, result CollectResult, metric Metric) CollectResult {
+	parse := c.metricConfig.Collector.Parse
+
+	if cmdResult.Error != nil {
+		result.Error = fmt.Errorf("command execution failed: %w", cmdResult.Error)
+		return result
+	}
+
+	re, err := regexp.Compile(parse.Pattern)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid regex pattern: %w", err)
+		return result
+	}
+
+	matches := re.FindStringSubmatch(cmdResult.Output)
+	c.traceMatch(re, matches)
+	if len(matches) <= parse.Index {
+		result.Error = fmt.Errorf("pattern didn't match or index out of range")
+		return result
+	}
+
+	states := stateSetStates(c.metricConfig.States, parse.StringMap)
+	state := matches[parse.Index]
+	c.tracer.printf("index %d: %q, states %v", parse.Index, state, states)
+	if !slices.Contains(states, state) {
+		result.Error = fmt.Errorf("unknown state '%s'", state)
+		return result
+	}
+
+	metric.States = states
+	metric.State = state
+	result.Metrics = []Metric{metric}
+	result.MetricValid = true
+
+	return result
+}
+
+// returns the configured states, or the sorted keys of the string map
+func stateSetStates(states []string, stringMap map[string]float64) []string {
+	if len(states) > 0 {
+		return states
+	}
+
+	keys := make([]string, 0, len(stringMap))
+	for k := range stringMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+func init() {
+	Register("table_parse", Registration{
+		New: func(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (Collector, error) {
+			return NewTableParseCollector(metricConfig, timeoutSec, exec)
+		},
+		Validate: config.ValidateTableParseCollector,
+	})
+}
+
+// collects metrics from columnar command output with a header row
+type TableParseCollector struct {
+	metricConfig config.MetricConfig
+	timeoutSec   int
+	executor     executor.Executor
+}
+
+// creates a new TableParseCollector
+func NewTableParseCollector(metricConfig config.MetricConfig, timeoutSec int, exec executor.Executor) (*TableParseCollector, error) {
+	if metricConfig.Collector.Table == nil {
+		return nil, fmt.Errorf("table_parse collector requires table configuration")
+	}
+
+	return &TableParseCollector{
+		metricConfig: metricConfig,
+		timeoutSec:   timeoutSec,
+		executor:     exec,
+	}, nil
+}
+
+// executes the command and emits one series per row, using one column as the
+// value and others as labels
+func (c *TableParseCollector) Collect() CollectResult {
+	collector := c.metricConfig.Collector
+	table := collector.Table
+
+	cmdResult := c.executor.Execute(
+		collector.Command,
+		c.timeoutSec,
+	)
+
+	result := CollectResult{
+		MetricValid: false,
+		ExitCode:    cmdResult.ExitCode,
+		Duration:    cmdResult.Duration,
+		TimedOut:    cmdResult.TimedOut,
+		Output:      cmdResult.Output,
+	}
+
+	if cmdResult.Error != nil {
+		result.Error = fmt.Errorf("command execution failed: %w", cmdResult.Error)
+		return result
+	}
+
+	rows, err := splitTable(cmdResult.Output, table.Delimiter, table.SkipLines)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if len(rows) == 0 {
+		result.Error = fmt.Errorf("no header row found in output")
+		return result
+	}
+
+	header := rows[0]
+	valueIndex := slices.Index(header, table.ValueColumn)
+	if valueIndex == -1 {
+		result.Error = fmt.Errorf("value column '%s' not found in header", table.ValueColumn)
+		return result
+	}
+
+	labelIndexes := make([]int, len(table.LabelColumns))
+	for i, column := range table.LabelColumns {
+		labelIndexes[i] = slices.Index(header, column)
+		if labelIndexes[i] == -1 {
+			result.Error = fmt.Errorf("label column '%s' not found in header", column)
+			return result
+		}
+	}
+
+	parse := &config.ParseConfig{
+		ValueType:  table.ValueType,
+		TimeLayout: table.TimeLayout,
+	}
+
+	// Rows must differ in their labels, as a textfile with duplicate series is rejected as a whole
+	seen := make(map[string]bool)
+	for _, row := range rows[1:] {
+		// Rows that are too short, such as totals or separators, are not data
+		if valueIndex >= len(row) || slices.ContainsFunc(labelIndexes, func(i int) bool { return i >= len(row) }) {
+			continue
+		}
+
+		value, err := parseNumber(row[valueIndex], parse)
+		if err != nil {
+			result.Error = fmt.Errorf("column '%s': %w", table.ValueColumn, err)
+			return result
+		}
+		if table.Multiplier != 0 {
+			value *= table.Multiplier
+		}
+
+		labels := make(map[string]string, len(table.LabelColumns))
+		for i, column := range table.LabelColumns {
+			labels[config.SanitizeName(column)] = row[labelIndexes[i]]
+		}
+
+		labelSet := labelSetKey(labels)
+		if seen[labelSet] {
+			if len(table.LabelColumns) == 0 {
+				result.Error = fmt.Errorf("several data rows without label_columns to tell them apart")
+			} else {
+				result.Error = fmt.Errorf("several data rows with labels {%s}", labelSet)
+			}
+			return result
+		}
+		seen[labelSet] = true
+
+		result.Metrics = append(result.Metrics, Metric{
+			Name:   c.metricConfig.Name,
+			Value:  value,
+			Type:   c.metricConfig.Type,
+			Help:   c.metricConfig.Help,
+			Labels: withLabels(collector.Labels, labels),
+		})
+	}
+
+	if len(result.Metrics) == 0 {
+		result.Error = fmt.Errorf("no data rows found in output")
+		return result
+	}
+
+	result.MetricValid = true
+	return result
+}
+
+// splits output into rows of fields, skipping leading lines and empty lines.
+// Without a delimiter, fields are separated by whitespace. A single-character
+// delimiter is parsed as CSV, so quoted fields may contain the delimiter.
+func splitTable(output, delimiter string, skipLines int) ([][]string, error) {
+	lines := strings.Split(output, "\n")
+	if skipLines >= len(lines) {
+		return nil, nil
+	}
+	lines = lines[skipLines:]
+
+	var nonEmpty []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+
+	var rows [][]string
+	switch {
+	case delimiter == "":
+		for _, line := range nonEmpty {
+			rows = append(rows, strings.Fields(line))
+		}
+	case utf8.RuneCountInString(delimiter) == 1:
+		reader := csv.NewReader(strings.NewReader(strings.Join(nonEmpty, "\n")))
+		reader.Comma, _ = utf8.DecodeRuneInString(delimiter)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse table: %w", err)
+		}
+		rows = records
+	default:
+		for _, line := range nonEmpty {
+			fields := strings.Split(line, delimiter)
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+			rows = append(rows, fields)
+		}
+	}
+
+	return rows, nil
+}
+
+// identifies a set of labels, such as {mount="/"}
+func labelSetKey(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, v))
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ",")
+}
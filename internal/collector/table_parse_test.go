@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+)
+
+func collectTable(t *testing.T, table config.TableParseConfig, output string) CollectResult {
+	t.Helper()
+	metricConfig := config.MetricConfig{
+		Name: "disk_usage",
+		Type: "gauge",
+		Collector: config.CollectorConfig{
+			Type:    "table_parse",
+			Command: "df",
+			Labels:  map[string]string{"host": "a"},
+			Table:   &table,
+		},
+	}
+	c, err := NewTableParseCollector(metricConfig, 1, executor.FixtureExecutor{Output: output})
+	if err != nil {
+		t.Fatalf("NewTableParseCollector() error = %v", err)
+	}
+	return c.Collect()
+}
+
+func TestTableParseCollect(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  config.TableParseConfig
+		output string
+		want   map[string]float64 // value of the first label column to value
+	}{
+		{
+			name:   "whitespace",
+			table:  config.TableParseConfig{ValueColumn: "Used", LabelColumns: []string{"Name"}},
+			output: "Name Used\na 1\nb 2\n",
+			want:   map[string]float64{"a": 1, "b": 2},
+		},
+		{
+			name:   "single row without label columns",
+			table:  config.TableParseConfig{ValueColumn: "Used"},
+			output: "Name Used\na 1\n",
+			want:   map[string]float64{"": 1},
+		},
+		{
+			name:   "csv with quoted delimiter",
+			table:  config.TableParseConfig{Delimiter: ",", ValueColumn: "Used", LabelColumns: []string{"Name"}},
+			output: "Name,Used\n\"a,b\", 3\n",
+			want:   map[string]float64{"a,b": 3},
+		},
+		{
+			name:   "skip lines, short rows, value type and multiplier",
+			table:  config.TableParseConfig{SkipLines: 1, ValueColumn: "Size", LabelColumns: []string{"Mount"}, ValueType: "bytes", Multiplier: 2},
+			output: "report\nMount Size\n/ 1K\ntotal\n",
+			want:   map[string]float64{"/": 2048},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := collectTable(t, tt.table, tt.output)
+			if !result.MetricValid {
+				t.Fatalf("Collect() error = %v", result.Error)
+			}
+
+			got := make(map[string]float64)
+			for _, metric := range result.Metrics {
+				label := ""
+				if len(tt.table.LabelColumns) > 0 {
+					label = metric.Labels[config.SanitizeName(tt.table.LabelColumns[0])]
+				}
+				if metric.Labels["host"] != "a" {
+					t.Errorf("Collect() labels = %v, want configured labels", metric.Labels)
+				}
+				got[label] = metric.Value
+			}
+			if len(got) != len(tt.want) || len(result.Metrics) != len(tt.want) {
+				t.Errorf("Collect() = %v, want %v", got, tt.want)
+			}
+			for label, value := range tt.want {
+				if got[label] != value {
+					t.Errorf("Collect() %q = %g, want %g", label, got[label], value)
+				}
+			}
+		})
+	}
+}
+
+func TestTableParseCollectErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  config.TableParseConfig
+		output string
+		want   string
+	}{
+		{"rows without label columns", config.TableParseConfig{ValueColumn: "Used"}, "Name Used\na 1\nb 2\n", "without label_columns"},
+		{"rows with the same labels", config.TableParseConfig{ValueColumn: "Used", LabelColumns: []string{"Name"}}, "Name Used\na 1\na 2\n", `several data rows with labels {name="a"}`},
+		{"missing value column", config.TableParseConfig{ValueColumn: "Free"}, "Name Used\na 1\n", "value column 'Free' not found"},
+		{"missing label column", config.TableParseConfig{ValueColumn: "Used", LabelColumns: []string{"Mount"}}, "Name Used\na 1\n", "label column 'Mount' not found"},
+		{"no data rows", config.TableParseConfig{ValueColumn: "Used"}, "Name Used\n", "no data rows"},
+		{"no output", config.TableParseConfig{ValueColumn: "Used"}, "", "no header row"},
+		{"value that is not a number", config.TableParseConfig{ValueColumn: "Used"}, "Name Used\na full\n", "column 'Used'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := collectTable(t, tt.table, tt.output)
+			if result.MetricValid {
+				t.Fatalf("Collect() = %v, want error", result.Metrics)
+			}
+			if result.Error == nil || !strings.Contains(result.Error.Error(), tt.want) {
+				t.Errorf("Collect() error = %v, want %q", result.Error, tt.want)
+			}
+		})
+	}
+}
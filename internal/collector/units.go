@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// multipliers of byte size units. Single-letter units follow df -h and ls -h,
+// which use powers of 1024.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"m":   1 << 20,
+	"g":   1 << 30,
+	"t":   1 << 40,
+	"p":   1 << 50,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+}
+
+// seconds per unit of human-readable durations
+var durationUnits = map[string]float64{
+	"ms": 0.001, "msec": 0.001, "millisecond": 0.001, "milliseconds": 0.001,
+	"s": 1, "sec": 1, "secs": 1, "second": 1, "seconds": 1,
+	"m": 60, "min": 60, "mins": 60, "minute": 60, "minutes": 60,
+	"h": 3600, "hr": 3600, "hrs": 3600, "hour": 3600, "hours": 3600,
+	"d": 86400, "day": 86400, "days": 86400,
+	"w": 604800, "week": 604800, "weeks": 604800,
+}
+
+// a number followed by a unit, e.g. "3 days" or "1.5h"
+var durationPartPattern = regexp.MustCompile(`(?i)([0-9]*\.?[0-9]+)\s*([a-z]+)`)
+
+// parses a byte size such as "1.5G", "512KiB" or "3 TB" into bytes
+func parseBytes(str string) (float64, error) {
+	str = strings.TrimSpace(str)
+	end := strings.IndexFunc(str, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != '-' && r != '+'
+	})
+	if end == -1 {
+		end = len(str)
+	}
+
+	number, err := strconv.ParseFloat(str[:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse byte size '%s': %w", str, err)
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(str[end:]))
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown byte size unit in '%s'", str)
+	}
+
+	return number * multiplier, nil
+}
+
+// parses a duration such as "1h2m", "00:05:30", "2-03:04:05" or "3 days" into seconds
+func parseDuration(str string) (float64, error) {
+	str = strings.TrimSpace(str)
+
+	// Go durations, e.g. "1h2m3.5s"
+	if d, err := time.ParseDuration(str); err == nil {
+		return d.Seconds(), nil
+	}
+
+	// Clock notation with optional days, e.g. "05:30", "00:05:30" or "2-03:04:05" as printed by ps
+	if strings.Contains(str, ":") {
+		return parseClockDuration(str)
+	}
+
+	// Plain number of seconds
+	if seconds, err := strconv.ParseFloat(str, 64); err == nil {
+		return seconds, nil
+	}
+
+	// Numbers with units, e.g. "3 days" or "2 hours, 5 minutes"
+	parts := durationPartPattern.FindAllStringSubmatch(str, -1)
+	if parts == nil {
+		return 0, fmt.Errorf("could not parse duration '%s'", str)
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		number, err := strconv.ParseFloat(part[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse duration '%s': %w", str, err)
+		}
+		unit, ok := durationUnits[strings.ToLower(part[2])]
+		if !ok {
+			return 0, fmt.Errorf("unknown duration unit '%s' in '%s'", part[2], str)
+		}
+		seconds += number * unit
+	}
+
+	return seconds, nil
+}
+
+// parses [[D-]HH:]MM:SS into seconds
+func parseClockDuration(str string) (float64, error) {
+	var days float64
+	if dayStr, rest, ok := strings.Cut(str, "-"); ok {
+		d, err := strconv.ParseFloat(dayStr, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse duration '%s': %w", str, err)
+		}
+		days = d
+		str = rest
+	}
+
+	fields := strings.Split(str, ":")
+	if len(fields) > 3 {
+		return 0, fmt.Errorf("could not parse duration '%s'", str)
+	}
+
+	var seconds float64
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse duration '%s': %w", str, err)
+		}
+		seconds = seconds*60 + value
+	}
+
+	return days*86400 + seconds, nil
+}
+
+// parses a percentage such as "87%" or "87" into a ratio
+func parsePercent(str string) (float64, error) {
+	str = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(str), "%"))
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse percent value: %w", err)
+	}
+	return value / 100, nil
+}
+
+// parses a date with the given layout, RFC 3339 by default, into Unix seconds
+func parseTimestamp(str, layout string) (float64, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	t, err := time.Parse(layout, strings.TrimSpace(str))
+	if err != nil {
+		return 0, fmt.Errorf("could not parse timestamp: %w", err)
+	}
+
+	return float64(t.UnixNano()) / 1e9, nil
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+)
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"512", 512},
+		{"512B", 512},
+		{"1.5G", 1.5 * (1 << 30)},
+		{"1.5g", 1.5 * (1 << 30)},
+		{"512KiB", 512 * (1 << 10)},
+		{"2MiB", 2 * (1 << 20)},
+		{"1K", 1 << 10},
+		{"4T", 4 * (1 << 40)},
+		{"3 TB", 3e12},
+		{"10kB", 10e3},
+		{"1MB", 1e6},
+		{"2GB", 2e9},
+		{" 7M ", 7 * (1 << 20)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseBytes(tt.input)
+		if err != nil {
+			t.Errorf("parseBytes(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBytes(%q) = %g, want %g", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "G", "1.5X", "12 apples"} {
+		if got, err := parseBytes(input); err == nil {
+			t.Errorf("parseBytes(%q) = %g, want error", input, got)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"1h2m", 3720},
+		{"1h2m3.5s", 3723.5},
+		{"250ms", 0.25},
+		{"90", 90},
+		{"1.5", 1.5},
+		{"05:30", 330},
+		{"00:05:30", 330},
+		{"2-03:04:05", 2*86400 + 3*3600 + 4*60 + 5},
+		{"3 days", 3 * 86400},
+		{"1 week", 604800},
+		{"2 hours, 5 minutes", 2*3600 + 5*60},
+		{"1 day 2 hrs", 86400 + 7200},
+		{"10 sec", 10},
+		{"500 msec", 0.5},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.input)
+		if err != nil {
+			t.Errorf("parseDuration(%q) error = %v", tt.input, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseDuration(%q) = %g, want %g", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "soon", "3 fortnights", "1:2:3:4", "a:b"} {
+		if got, err := parseDuration(input); err == nil {
+			t.Errorf("parseDuration(%q) = %g, want error", input, got)
+		}
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"87%", 0.87},
+		{"87", 0.87},
+		{" 100 % ", 1},
+		{"0.5%", 0.005},
+		{"0%", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePercent(tt.input)
+		if err != nil {
+			t.Errorf("parsePercent(%q) error = %v", tt.input, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("parsePercent(%q) = %g, want %g", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "%", "high"} {
+		if got, err := parsePercent(input); err == nil {
+			t.Errorf("parsePercent(%q) = %g, want error", input, got)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		input  string
+		layout string
+		want   float64
+	}{
+		{"2024-01-02T03:04:05Z", "", 1704164645},
+		{"2024-01-02T03:04:05+01:00", "", 1704164645 - 3600},
+		{"2024-01-02T03:04:05.5Z", "", 1704164645.5},
+		{"2024-01-02 03:04:05", "2006-01-02 15:04:05", 1704164645},
+		{" Jan 2 2024 ", "Jan 2 2006", 1704153600},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.input, tt.layout)
+		if err != nil {
+			t.Errorf("parseTimestamp(%q, %q) error = %v", tt.input, tt.layout, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTimestamp(%q, %q) = %f, want %f", tt.input, tt.layout, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "yesterday", "2024-01-02 03:04:05"} {
+		if got, err := parseTimestamp(input, ""); err == nil {
+			t.Errorf("parseTimestamp(%q) = %f, want error", input, got)
+		}
+	}
+}
+
+func TestParseNumberValueTypes(t *testing.T) {
+	tests := []struct {
+		parse config.ParseConfig
+		input string
+		want  float64
+	}{
+		{config.ParseConfig{ValueType: "bytes"}, "1.5G", 1.5 * (1 << 30)},
+		{config.ParseConfig{ValueType: "duration"}, "2-03:04:05", 2*86400 + 3*3600 + 4*60 + 5},
+		{config.ParseConfig{ValueType: "percent"}, "87%", 0.87},
+		{config.ParseConfig{ValueType: "timestamp", TimeLayout: "2006-01-02 15:04:05"}, "2024-01-02 03:04:05", 1704164645},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNumber(tt.input, &tt.parse)
+		if err != nil {
+			t.Errorf("parseNumber(%q) with value_type %s error = %v", tt.input, tt.parse.ValueType, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("parseNumber(%q) with value_type %s = %g, want %g", tt.input, tt.parse.ValueType, got, tt.want)
+		}
+	}
+}
@@ -2,67 +2,156 @@ package collector
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 
 	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/expr"
 )
 
-// converts an extracted string to a float64 value based on parse configuration
-func convertValue(str string, parse *config.ParseConfig) (float64, error) {
+// converts an extracted string to a float64 value based on parse configuration,
+// reporting whether the default value was used for an unmapped string
+func convertValue(str string, parse *config.ParseConfig, trace Tracer) (float64, bool, error) {
 	var value float64
 	var err error
+	defaultUsed := false
 
 	// If StringMap is defined, mapping is preferred
 	if parse.StringMap != nil && len(parse.StringMap) > 0 {
 		if mappedValue, ok := parse.StringMap[str]; ok {
 			value = mappedValue
+			trace.printf("string_map: %q -> %g", str, value)
 		} else if parse.DefaultValue != nil {
 			value = *parse.DefaultValue
+			defaultUsed = true
+			trace.printf("string_map: %q not found, using default value %g", str, value)
 		} else {
-			return 0, fmt.Errorf("string '%s' not found in mapping", str)
+			return 0, false, fmt.Errorf("string '%s' not found in mapping", str)
 		}
 	} else {
-		switch parse.ValueType {
-		case "", "float":
-			value, err = strconv.ParseFloat(str, 64)
-			if err != nil {
-				return 0, fmt.Errorf("could not parse float value: %w", err)
-			}
-		case "int":
-			intVal, err := strconv.ParseInt(str, 10, 64)
-			if err != nil {
-				return 0, fmt.Errorf("could not parse int value: %w", err)
-			}
-			value = float64(intVal)
-		case "bool":
-			boolVal, err := strconv.ParseBool(str)
-			if err != nil {
-				return 0, fmt.Errorf("could not parse bool value: %w", err)
-			}
-			if boolVal {
-				value = 1
-			} else {
-				value = 0
-			}
-		case "bool_nonzero":
-			intVal, err := strconv.ParseInt(str, 10, 64)
-			if err != nil {
-				return 0, fmt.Errorf("could not parse int value for bool_nonzero: %w", err)
-			}
-			if intVal != 0 {
-				value = 1
-			} else {
-				value = 0
-			}
-		default:
-			return 0, fmt.Errorf("unsupported value type: %s", parse.ValueType)
+		value, err = parseNumber(str, parse)
+		if err != nil {
+			return 0, false, err
 		}
+		trace.printf("value_type %s: %q -> %g", valueTypeName(parse.ValueType), str, value)
 	}
 
 	// Multiplier applied ( 0 or not multiplied if not specified )
 	if parse.Multiplier != 0 {
 		value *= parse.Multiplier
+		trace.printf("multiplier %g: -> %g", parse.Multiplier, value)
+	}
+
+	return value, defaultUsed, nil
+}
+
+// parses an extracted string as a number according to the value type
+func parseNumber(str string, parse *config.ParseConfig) (float64, error) {
+	var value float64
+	var err error
+
+	switch parse.ValueType {
+	case "", "float":
+		value, err = strconv.ParseFloat(str, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse float value: %w", err)
+		}
+	case "int":
+		intVal, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse int value: %w", err)
+		}
+		value = float64(intVal)
+	case "bool":
+		boolVal, err := strconv.ParseBool(str)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse bool value: %w", err)
+		}
+		if boolVal {
+			value = 1
+		} else {
+			value = 0
+		}
+	case "bool_nonzero":
+		intVal, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse int value for bool_nonzero: %w", err)
+		}
+		if intVal != 0 {
+			value = 1
+		} else {
+			value = 0
+		}
+	case "bytes":
+		value, err = parseBytes(str)
+		if err != nil {
+			return 0, err
+		}
+	case "duration":
+		value, err = parseDuration(str)
+		if err != nil {
+			return 0, err
+		}
+	case "percent":
+		value, err = parsePercent(str)
+		if err != nil {
+			return 0, err
+		}
+	case "timestamp":
+		value, err = parseTimestamp(str, parse.TimeLayout)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unsupported value type: %s", parse.ValueType)
 	}
 
 	return value, nil
 }
+
+// evaluates the parse expression over a match. Named captures are parsed
+// according to the value type, and "value" is the converted value at Index.
+func evaluateExpression(re *regexp.Regexp, matches []string, parse *config.ParseConfig, trace Tracer) (float64, bool, error) {
+	e, err := expr.Parse(parse.Expression)
+	if err != nil {
+		return 0, false, err
+	}
+
+	vars := make(map[string]float64)
+	defaultUsed := false
+	for _, name := range e.Variables() {
+		if name == "value" {
+			vars[name], defaultUsed, err = convertValue(matches[parse.Index], parse, trace)
+			if err != nil {
+				return 0, false, err
+			}
+			continue
+		}
+
+		index := re.SubexpIndex(name)
+		if index == -1 {
+			return 0, false, fmt.Errorf("no capture group named '%s'", name)
+		}
+		vars[name], err = parseNumber(matches[index], parse)
+		if err != nil {
+			return 0, false, fmt.Errorf("capture '%s': %w", name, err)
+		}
+		trace.printf("capture %s, value_type %s: %q -> %g", name, valueTypeName(parse.ValueType), matches[index], vars[name])
+	}
+
+	value, err := e.Eval(vars)
+	if err != nil {
+		return 0, false, fmt.Errorf("could not evaluate expression: %w", err)
+	}
+	trace.printf("expression %s: -> %g", parse.Expression, value)
+
+	return value, defaultUsed, nil
+}
+
+// returns the value type as written in the configuration, with the default filled in
+func valueTypeName(valueType string) string {
+	if valueType == "" {
+		return "float"
+	}
+	return valueType
+}
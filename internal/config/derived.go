@@ -0,0 +1,64 @@
+package config
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// returns the keys of the metrics a derived metric reads, found by the series
+// names of its selector and denominator
+func (c *Config) DerivedInputs(key string) []string {
+	derived := c.Metrics[key].Collector.Derived
+	if derived == nil {
+		return nil
+	}
+
+	inputs := c.seriesSources(derived.Metric)
+	if derived.Denominator != nil {
+		for _, input := range c.seriesSources(derived.Denominator.Metric) {
+			if !slices.Contains(inputs, input) {
+				inputs = append(inputs, input)
+			}
+		}
+	}
+	sort.Strings(inputs)
+
+	return inputs
+}
+
+// returns the keys of the non-derived metrics that may emit series named name
+func (c *Config) seriesSources(name string) []string {
+	var sources []string
+	for key, metric := range c.Metrics {
+		if metric.Collector.Derived == nil && emitsSeries(metric, name) {
+			sources = append(sources, key)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// reports whether a metric may emit series named name: its own name, the
+// <name>_collected_at_seconds companion, and <name>_<key> series of kv_parse
+func emitsSeries(metric MetricConfig, name string) bool {
+	if name == metric.Name {
+		return true
+	}
+	if metric.CollectedAt && name == metric.Name+"_collected_at_seconds" {
+		return true
+	}
+
+	kv := metric.Collector.KV
+	if kv == nil || kv.Mode == "label" {
+		return false
+	}
+	key, ok := strings.CutPrefix(name, metric.Name+"_")
+	if !ok {
+		return false
+	}
+	// With explicit keys the series names are known, otherwise any key may appear
+	return len(kv.Keys) == 0 || slices.ContainsFunc(kv.Keys, func(k string) bool {
+		return key == SanitizeName(k)
+	})
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSeriesSources(t *testing.T) {
+	cfg := &Config{Metrics: map[string]MetricConfig{
+		"disk":     {Name: "disk", CollectedAt: true},
+		"mdadm":    {Name: "mdadm", Collector: CollectorConfig{KV: &KVParseConfig{}}},
+		"sensors":  {Name: "sensors", Collector: CollectorConfig{KV: &KVParseConfig{Keys: []string{"CPU Temp"}}}},
+		"labelled": {Name: "labelled", Collector: CollectorConfig{KV: &KVParseConfig{Mode: "label"}}},
+		"total":    {Name: "disk_total", Collector: CollectorConfig{Derived: &DerivedConfig{Selector: Selector{Metric: "disk"}}}},
+	}}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"disk", []string{"disk"}},
+		{"disk_bytez", nil},
+		{"disk_collected_at_seconds", []string{"disk"}},
+		{"mdadm_active_devices", []string{"mdadm"}},
+		{"sensors_cpu_temp", []string{"sensors"}},
+		{"sensors_fan", nil},
+		{"labelled", []string{"labelled"}},
+		{"labelled_key", nil},
+		{"disk_total", nil},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.seriesSources(tt.name); !slices.Equal(got, tt.want) {
+			t.Errorf("seriesSources(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -6,8 +6,19 @@ import (
 	"regexp"
 
 	"github.com/goccy/go-yaml"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/expr"
 )
 
+// valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// output groups become file names in the textfile directory
+var outputGroupPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// tags are given as a comma-separated list to run -tags
+var tagPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
 // loads and validates the configuration from a file
 func LoadConfig(filename string) (*Config, error) {
 	// Read configuration file
@@ -16,10 +27,11 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Parse YAML
+	// Parse YAML, rejecting unknown keys so that a typo does not silently
+	// disable a setting
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if err := yaml.UnmarshalWithOptions(data, &config, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %s", yaml.FormatError(err, false, false))
 	}
 
 	// Validate configuration
@@ -30,6 +42,15 @@ func LoadConfig(filename string) (*Config, error) {
 	return &config, nil
 }
 
+// validates a configuration that was not loaded from a file, such as one
+// built in code by a program embedding the exporter
+func ValidateConfig(config *Config) error {
+	if err := validateConfig(config); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
 // validates the configuration
 func validateConfig(config *Config) error {
 	if len(config.Metrics) == 0 {
@@ -43,6 +64,34 @@ func validateConfig(config *Config) error {
 		}
 	}
 
+	// Derived metrics must refer to metrics that are collected before them
+	for name, metric := range config.Metrics {
+		if err := validateDerivedInputs(config, metric); err != nil {
+			return fmt.Errorf("invalid metric '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that the selector and denominator of a derived metric refer to
+// configured metrics that are not derived themselves
+func validateDerivedInputs(config *Config, metric MetricConfig) error {
+	derived := metric.Collector.Derived
+	if derived == nil {
+		return nil
+	}
+
+	selectors := []Selector{derived.Selector}
+	if derived.Denominator != nil {
+		selectors = append(selectors, *derived.Denominator)
+	}
+	for _, selector := range selectors {
+		if len(config.seriesSources(selector.Metric)) == 0 {
+			return fmt.Errorf("derived metric '%s' does not refer to a configured metric that is not derived", selector.Metric)
+		}
+	}
+
 	return nil
 }
 
@@ -57,35 +106,213 @@ func validateMetric(name string, metric MetricConfig) error {
 	if metric.Type == "" {
 		return fmt.Errorf("metric type is required")
 	}
-	if metric.Type != "gauge" && metric.Type != "counter" {
-		return fmt.Errorf("metric type must be 'gauge' or 'counter', got '%s'", metric.Type)
+	switch metric.Type {
+	case "gauge", "counter", "untyped", "info", "stateset":
+	case "histogram", "summary":
+		if err := validateDistribution(metric); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("metric type must be 'gauge', 'counter', 'untyped', 'histogram', 'summary', 'info' or 'stateset', got '%s'", metric.Type)
+	}
+	if metric.Type != "histogram" && len(metric.Buckets) > 0 {
+		return fmt.Errorf("buckets require metric type 'histogram'")
+	}
+	if metric.Type != "summary" && len(metric.Quantiles) > 0 {
+		return fmt.Errorf("quantiles require metric type 'summary'")
+	}
+	if metric.Type != "stateset" && len(metric.States) > 0 {
+		return fmt.Errorf("states require metric type 'stateset'")
+	}
+
+	// Validate output group
+	if metric.Output != "" && !outputGroupPattern.MatchString(metric.Output) {
+		return fmt.Errorf("output group must match %s, got '%s'", outputGroupPattern, metric.Output)
+	}
+
+	for _, tag := range metric.Tags {
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("tags must match %s, got '%s'", tagPattern, tag)
+		}
+	}
+
+	if metric.Collector.Accumulate && metric.Type != "counter" {
+		return fmt.Errorf("accumulate requires metric type 'counter', got '%s'", metric.Type)
+	}
+
+	switch metric.OnFailure {
+	case "", "default", "keep_last", "drop":
+	default:
+		return fmt.Errorf("on_failure must be 'default', 'keep_last' or 'drop', got '%s'", metric.OnFailure)
+	}
+
+	if metric.MaxAge < 0 {
+		return fmt.Errorf("max_age must be non-negative")
+	}
+
+	if metric.Collector.CacheTTL < 0 {
+		return fmt.Errorf("cache_ttl must be non-negative")
+	}
+
+	if err := validateTests(metric); err != nil {
+		return err
 	}
 
-	// Validate collector
-	return validateCollector(metric.Collector)
+	// Validate collector, including the checks specific to its type
+	return validateCollector(metric)
 }
 
-// validates the collector configuration
-func validateCollector(collector CollectorConfig) error {
-	// Check command
-	if collector.Command == "" {
-		return fmt.Errorf("collector command is required")
+// validates the fixture test cases of a metric
+func validateTests(metric MetricConfig) error {
+	for i, test := range metric.Tests {
+		name := test.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if test.Output != "" && test.OutputFile != "" {
+			return fmt.Errorf("test %s: output and output_file cannot both be set", name)
+		}
+
+		expectations := 0
+		if test.Value != nil {
+			expectations++
+		}
+		if len(test.Series) > 0 {
+			expectations++
+		}
+		if test.Error != "" {
+			expectations++
+		}
+		if expectations != 1 {
+			return fmt.Errorf("test %s: exactly one of value, series or error must be set", name)
+		}
+		if len(test.Labels) > 0 && test.Value == nil {
+			return fmt.Errorf("test %s: labels require value", name)
+		}
+		if test.Value != nil {
+			switch metric.Type {
+			case "histogram", "summary", "stateset":
+				return fmt.Errorf("test %s: metric type '%s' has no single value, use series", name, metric.Type)
+			}
+		}
 	}
 
-	// Check collector type
-	switch collector.Type {
-	case "returncode":
-		// No additional validation needed
-	case "returncode_mapping":
-		if collector.Mapping == nil || len(collector.Mapping) == 0 {
-			return fmt.Errorf("returncode_mapping requires a mapping configuration")
+	return nil
+}
+
+// validates the configuration of a histogram or summary metric
+func validateDistribution(metric MetricConfig) error {
+	if metric.Type == "histogram" {
+		if len(metric.Buckets) == 0 {
+			return fmt.Errorf("histogram requires buckets")
 		}
-	case "output_parse":
-		if err := validateParseConfig(collector.Parse); err != nil {
-			return err
+		for i := 1; i < len(metric.Buckets); i++ {
+			if metric.Buckets[i] <= metric.Buckets[i-1] {
+				return fmt.Errorf("histogram buckets must be in increasing order")
+			}
 		}
+	}
+
+	for _, q := range metric.Quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("summary quantiles must be between 0 and 1, got %g", q)
+		}
+	}
+
+	return nil
+}
+
+// validates the configuration of an info metric, whose labels are taken from
+// the named capture groups of the parse pattern
+func validateInfo(metric MetricConfig) error {
+	if err := rejectValueSettings(metric); err != nil {
+		return err
+	}
+
+	re, err := regexp.Compile(metric.Collector.Parse.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regular expression pattern: %w", err)
+	}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("metric type 'info' requires named capture groups in the parse pattern")
+}
+
+// validates the configuration of a stateset metric
+func validateStateSet(metric MetricConfig) error {
+	if err := rejectValueSettings(metric); err != nil {
+		return err
+	}
+	if len(metric.States) == 0 && len(metric.Collector.Parse.StringMap) == 0 {
+		return fmt.Errorf("stateset requires states or parse.string_map")
+	}
+
+	return nil
+}
+
+// rejects the parse settings that convert a number, which info and stateset
+// metrics do not extract
+func rejectValueSettings(metric MetricConfig) error {
+	parse := metric.Collector.Parse
+	var setting string
+	switch {
+	case parse.DefaultValue != nil:
+		setting = "default_value"
+	case parse.Multiplier != 0:
+		setting = "multiplier"
+	case parse.ValueType != "":
+		setting = "value_type"
+	case parse.Expression != "":
+		setting = "expression"
 	default:
-		return fmt.Errorf("unknown collector type: %s", collector.Type)
+		return nil
+	}
+
+	return fmt.Errorf("%s cannot be used with metric type '%s'", setting, metric.Type)
+}
+
+// validates the collector configuration of a metric with the validator of its collector type
+func validateCollector(metric MetricConfig) error {
+	validate, ok := collectorValidator(metric.Collector.Type)
+	if !ok {
+		return fmt.Errorf("unknown collector type: %s", metric.Collector.Type)
+	}
+	if validate == nil {
+		return nil
+	}
+
+	return validate(metric)
+}
+
+// validates the configuration of a derived collector
+func validateDerivedConfig(collector CollectorConfig) error {
+	derived := collector.Derived
+	if derived == nil {
+		return fmt.Errorf("derived configuration is required")
+	}
+	if collector.Command != "" {
+		return fmt.Errorf("derived collector does not execute a command")
+	}
+	if derived.Metric == "" {
+		return fmt.Errorf("derived metric is required")
+	}
+
+	switch derived.Function {
+	case "sum", "min", "max", "avg", "count":
+		if derived.Denominator != nil {
+			return fmt.Errorf("denominator requires function 'ratio'")
+		}
+	case "ratio":
+		if derived.Denominator == nil || derived.Denominator.Metric == "" {
+			return fmt.Errorf("function 'ratio' requires a denominator metric")
+		}
+	default:
+		return fmt.Errorf("derived function must be 'sum', 'min', 'max', 'avg', 'count' or 'ratio', got '%s'", derived.Function)
 	}
 
 	return nil
@@ -96,16 +323,111 @@ func validateParseConfig(parse *ParseConfig) error {
 	if parse == nil {
 		return fmt.Errorf("parse configuration is required")
 	}
-	if parse.Pattern == "" {
+	switch parse.Mode {
+	case "", "value":
+	case "line_count", "match_count", "match_exists":
+		if parse.Expression != "" || len(parse.StringMap) > 0 || parse.ValueType != "" {
+			return fmt.Errorf("parse mode '%s' does not use expression, string_map or value_type", parse.Mode)
+		}
+	default:
+		return fmt.Errorf("parse mode must be 'value', 'line_count', 'match_count' or 'match_exists', got '%s'", parse.Mode)
+	}
+	// Counting lines does not require a pattern, it then counts all non-empty lines
+	if parse.Pattern == "" && parse.Mode != "line_count" {
 		return fmt.Errorf("parse pattern is required")
 	}
 	// Check if pattern is a valid regular expression
-	_, err := regexp.Compile(parse.Pattern)
+	re, err := regexp.Compile(parse.Pattern)
 	if err != nil {
 		return fmt.Errorf("invalid regular expression pattern: %w", err)
 	}
 	if parse.Index < 0 {
 		return fmt.Errorf("parse index must be non-negative")
 	}
+	if err := validateValueType(parse.ValueType, parse.TimeLayout); err != nil {
+		return err
+	}
+	if parse.Expression != "" {
+		if err := validateExpression(parse.Expression, re); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validates a value type and its time layout
+func validateValueType(valueType, timeLayout string) error {
+	switch valueType {
+	case "", "float", "int", "bool", "bool_nonzero", "bytes", "duration", "percent", "timestamp":
+	default:
+		return fmt.Errorf("unsupported value type: %s", valueType)
+	}
+	if timeLayout != "" && valueType != "timestamp" {
+		return fmt.Errorf("time_layout requires value_type 'timestamp'")
+	}
+	return nil
+}
+
+// validates the configuration of a kv_parse collector
+func validateKVParseConfig(kv *KVParseConfig) error {
+	if kv == nil {
+		return fmt.Errorf("kv configuration is required")
+	}
+
+	switch kv.Mode {
+	case "", "metric":
+		if kv.KeyLabel != "" {
+			return fmt.Errorf("key_label requires mode 'label'")
+		}
+	case "label":
+		if kv.KeyLabel != "" && !labelNamePattern.MatchString(kv.KeyLabel) {
+			return fmt.Errorf("invalid key_label '%s'", kv.KeyLabel)
+		}
+	default:
+		return fmt.Errorf("kv mode must be 'metric' or 'label', got '%s'", kv.Mode)
+	}
+
+	if kv.ValuePattern != "" {
+		re, err := regexp.Compile(kv.ValuePattern)
+		if err != nil {
+			return fmt.Errorf("invalid value_pattern: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return fmt.Errorf("value_pattern requires a capture group")
+		}
+	}
+
+	return validateValueType(kv.ValueType, kv.TimeLayout)
+}
+
+// validates the configuration of a table_parse collector
+func validateTableParseConfig(table *TableParseConfig) error {
+	if table == nil {
+		return fmt.Errorf("table configuration is required")
+	}
+	if table.ValueColumn == "" {
+		return fmt.Errorf("table value_column is required")
+	}
+	if table.SkipLines < 0 {
+		return fmt.Errorf("table skip_lines must be non-negative")
+	}
+
+	return validateValueType(table.ValueType, table.TimeLayout)
+}
+
+// validates that an expression parses and only references "value" and named
+// capture groups of the pattern
+func validateExpression(expression string, re *regexp.Regexp) error {
+	e, err := expr.Parse(expression)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range e.Variables() {
+		if name != "value" && re.SubexpIndex(name) == -1 {
+			return fmt.Errorf("expression references '%s', which is neither 'value' nor a named capture group", name)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTests(t *testing.T) {
+	one := 1.0
+
+	tests := []struct {
+		metricType string
+		test       MetricTest
+		want       string
+	}{
+		{metricType: "gauge", test: MetricTest{Value: &one}},
+		{metricType: "stateset", test: MetricTest{Series: []ExpectedSeries{{Value: 1}}}},
+		{metricType: "gauge", test: MetricTest{Value: &one, Error: "failed"}, want: "exactly one of value, series or error"},
+		{metricType: "gauge", test: MetricTest{Labels: map[string]string{"a": "b"}, Error: "failed"}, want: "labels require value"},
+		{metricType: "histogram", test: MetricTest{Value: &one}, want: "metric type 'histogram' has no single value"},
+		{metricType: "summary", test: MetricTest{Value: &one}, want: "metric type 'summary' has no single value"},
+		{metricType: "stateset", test: MetricTest{Value: &one}, want: "metric type 'stateset' has no single value"},
+	}
+
+	for _, tt := range tests {
+		metric := MetricConfig{Name: "test_metric", Type: tt.metricType, Tests: []MetricTest{tt.test}}
+		err := validateTests(metric)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("validateTests(%s) error = %v, want nil", tt.metricType, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("validateTests(%s) error = %v, want %q", tt.metricType, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// matches runs of characters that are not allowed in metric and label names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// turns arbitrary text such as "Active Devices" into a name such as "active_devices"
+func SanitizeName(str string) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(str), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Active Devices", "active_devices"},
+		{"active-devices", "active_devices"},
+		{" Used (%) ", "used"},
+		{"1st", "_1st"},
+		{"---", "_"},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeName(tt.input); got != tt.want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// CollectorValidator validates the collector configuration of a metric using a
+// collector type, including the metric settings the type does not support
+type CollectorValidator func(metric MetricConfig) error
+
+var (
+	validatorsMu sync.RWMutex
+	// validators of the registered collector types, nil if a type needs no validation
+	validators = make(map[string]CollectorValidator)
+)
+
+// makes a collector type known to configuration validation. Collector types
+// are registered with collector.Register, which also registers their constructor.
+func RegisterCollectorType(collectorType string, validate CollectorValidator) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+
+	validators[collectorType] = validate
+}
+
+// returns the validator of a collector type, and whether the type is registered
+func collectorValidator(collectorType string) (CollectorValidator, bool) {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+
+	validate, ok := validators[collectorType]
+	return validate, ok
+}
+
+// returns the sorted names of the registered collector types
+func collectorTypes() []string {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+
+	types := make([]string, 0, len(validators))
+	for t := range validators {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// validates the configuration of a returncode collector
+func ValidateReturnCodeCollector(metric MetricConfig) error {
+	if err := requireSingleValueType(metric); err != nil {
+		return err
+	}
+	return requireCommand(metric.Collector)
+}
+
+// validates the configuration of a returncode_mapping collector
+func ValidateReturnCodeMappingCollector(metric MetricConfig) error {
+	if err := requireSingleValueType(metric); err != nil {
+		return err
+	}
+	if err := requireCommand(metric.Collector); err != nil {
+		return err
+	}
+	if len(metric.Collector.Mapping) == 0 {
+		return fmt.Errorf("returncode_mapping requires a mapping configuration")
+	}
+	return nil
+}
+
+// validates the configuration of an output_parse collector, the only built-in
+// collector supporting histogram, summary, info and stateset metrics
+func ValidateOutputParseCollector(metric MetricConfig) error {
+	if err := requireCommand(metric.Collector); err != nil {
+		return err
+	}
+	parse := metric.Collector.Parse
+	if err := validateParseConfig(parse); err != nil {
+		return err
+	}
+
+	if parse.Mode != "" && parse.Mode != "value" {
+		switch metric.Type {
+		case "histogram", "summary", "info", "stateset":
+			return fmt.Errorf("parse mode '%s' cannot be used with metric type '%s'", parse.Mode, metric.Type)
+		}
+	}
+
+	switch metric.Type {
+	case "histogram", "summary":
+		// There is no single value to replace when an observation does not parse
+		if parse.DefaultValue != nil {
+			return fmt.Errorf("default_value cannot be used with metric type '%s'", metric.Type)
+		}
+	case "info":
+		return validateInfo(metric)
+	case "stateset":
+		return validateStateSet(metric)
+	}
+	return nil
+}
+
+// validates the configuration of a kv_parse collector
+func ValidateKVParseCollector(metric MetricConfig) error {
+	if err := requireSingleValueType(metric); err != nil {
+		return err
+	}
+	if metric.Collector.Accumulate {
+		return fmt.Errorf("accumulate is not supported by the kv_parse collector")
+	}
+	if err := requireCommand(metric.Collector); err != nil {
+		return err
+	}
+	return validateKVParseConfig(metric.Collector.KV)
+}
+
+// validates the configuration of a table_parse collector
+func ValidateTableParseCollector(metric MetricConfig) error {
+	if err := requireSingleValueType(metric); err != nil {
+		return err
+	}
+	if metric.Collector.Accumulate {
+		return fmt.Errorf("accumulate is not supported by the table_parse collector")
+	}
+	if err := requireCommand(metric.Collector); err != nil {
+		return err
+	}
+	return validateTableParseConfig(metric.Collector.Table)
+}
+
+// validates the configuration of a derived collector, which computes its value
+// from other metrics instead of executing a command
+func ValidateDerivedCollector(metric MetricConfig) error {
+	if err := requireSingleValueType(metric); err != nil {
+		return err
+	}
+	if len(metric.Tests) > 0 {
+		return fmt.Errorf("tests are not supported by the derived collector")
+	}
+	return validateDerivedConfig(metric.Collector)
+}
+
+func requireCommand(collector CollectorConfig) error {
+	if collector.Command == "" {
+		return fmt.Errorf("collector command is required")
+	}
+	return nil
+}
+
+// rejects the metric types whose series only the output_parse collector produces
+func requireSingleValueType(metric MetricConfig) error {
+	switch metric.Type {
+	case "histogram", "summary", "info", "stateset":
+		return fmt.Errorf("metric type '%s' requires the output_parse collector", metric.Type)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateOutputParseCollector(t *testing.T) {
+	zero := 0.0
+
+	tests := []struct {
+		name   string
+		metric MetricConfig
+		want   string
+	}{
+		{
+			name:   "gauge with default_value",
+			metric: outputParseMetric("gauge", ParseConfig{Pattern: `(\d+)`, Index: 1, DefaultValue: &zero}),
+		},
+		{
+			name:   "histogram",
+			metric: outputParseMetric("histogram", ParseConfig{Pattern: `(\d+)`, Index: 1}),
+		},
+		{
+			name:   "histogram with default_value",
+			metric: outputParseMetric("histogram", ParseConfig{Pattern: `(\d+)`, Index: 1, DefaultValue: &zero}),
+			want:   "default_value cannot be used with metric type 'histogram'",
+		},
+		{
+			name:   "summary with default_value",
+			metric: outputParseMetric("summary", ParseConfig{Pattern: `(\d+)`, Index: 1, DefaultValue: &zero}),
+			want:   "default_value cannot be used with metric type 'summary'",
+		},
+		{
+			name:   "info with default_value",
+			metric: outputParseMetric("info", ParseConfig{Pattern: `(?P<version>\S+)`, DefaultValue: &zero}),
+			want:   "default_value cannot be used with metric type 'info'",
+		},
+		{
+			name:   "info with expression",
+			metric: outputParseMetric("info", ParseConfig{Pattern: `(?P<version>\S+)`, Expression: "version"}),
+			want:   "expression cannot be used with metric type 'info'",
+		},
+		{
+			name:   "stateset",
+			metric: outputParseMetric("stateset", ParseConfig{Pattern: `(\w+)`, Index: 1, StringMap: map[string]float64{"active": 1}}),
+		},
+		{
+			name:   "stateset with multiplier",
+			metric: outputParseMetric("stateset", ParseConfig{Pattern: `(\w+)`, Index: 1, StringMap: map[string]float64{"active": 1}, Multiplier: 2}),
+			want:   "multiplier cannot be used with metric type 'stateset'",
+		},
+		{
+			name:   "stateset with value_type",
+			metric: outputParseMetric("stateset", ParseConfig{Pattern: `(\w+)`, Index: 1, StringMap: map[string]float64{"active": 1}, ValueType: "int"}),
+			want:   "value_type cannot be used with metric type 'stateset'",
+		},
+		{
+			name:   "summary with line_count",
+			metric: outputParseMetric("summary", ParseConfig{Mode: "line_count"}),
+			want:   "parse mode 'line_count' cannot be used with metric type 'summary'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOutputParseCollector(tt.metric)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("ValidateOutputParseCollector() error = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("ValidateOutputParseCollector() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func outputParseMetric(metricType string, parse ParseConfig) MetricConfig {
+	return MetricConfig{
+		Name: "test_metric",
+		Type: metricType,
+		Collector: CollectorConfig{
+			Type:    "output_parse",
+			Command: "true",
+			Parse:   &parse,
+		},
+	}
+}
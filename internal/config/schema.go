@@ -0,0 +1,145 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// URI of the JSON Schema dialect of the generated schema
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durations as accepted by time.ParseDuration, without a sign as negative
+// durations are rejected by validation
+const durationPattern = `^(0|((\d+(\.\d*)?|\.\d+)(ns|us|µs|μs|ms|s|m|h))+)$`
+
+// fields that validation requires, by struct type
+var schemaRequired = map[string][]string{
+	"Config":           {"metrics"},
+	"MetricConfig":     {"name", "type", "collector"},
+	"CollectorConfig":  {"type"},
+	"DerivedConfig":    {"function", "metric"},
+	"Selector":         {"metric"},
+	"TableParseConfig": {"value_column"},
+}
+
+var valueTypes = []string{"float", "int", "bool", "bool_nonzero", "bytes", "duration", "percent", "timestamp"}
+
+// allowed values of string fields, by struct type and YAML key
+var schemaEnums = map[string][]string{
+	"MetricConfig.type":           {"gauge", "counter", "untyped", "histogram", "summary", "info", "stateset"},
+	"MetricConfig.on_failure":     {"default", "keep_last", "drop"},
+	"ParseConfig.mode":            {"value", "line_count", "match_count", "match_exists"},
+	"ParseConfig.value_type":      valueTypes,
+	"KVParseConfig.mode":          {"metric", "label"},
+	"KVParseConfig.value_type":    valueTypes,
+	"TableParseConfig.value_type": valueTypes,
+	"DerivedConfig.function":      {"sum", "min", "max", "avg", "count", "ratio"},
+}
+
+// returns a JSON Schema of the configuration file, for validation and
+// completion in editors. Collector types are those registered when it is called.
+func Schema() map[string]any {
+	g := schemaGenerator{defs: make(map[string]any)}
+	schema := g.schemaOf(reflect.TypeOf(Config{}))
+
+	if collector, ok := g.defs["CollectorConfig"].(map[string]any); ok {
+		properties := collector["properties"].(map[string]any)
+		properties["type"] = map[string]any{"type": "string", "enum": collectorTypes()}
+	}
+
+	root := map[string]any{
+		"$schema": schemaDialect,
+		"title":   "prom-textfile-exporter configuration",
+		"$defs":   g.defs,
+	}
+	for k, v := range schema {
+		root[k] = v
+	}
+	return root
+}
+
+// builds the schemas of Go types, keeping named structs in $defs
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+// returns a reference to the schema of a struct, generating it on first use
+func (g *schemaGenerator) structRef(t reflect.Type) map[string]any {
+	ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+	if _, ok := g.defs[t.Name()]; ok {
+		return ref
+	}
+	// Reserve the name so that recursive types terminate
+	g.defs[t.Name()] = nil
+
+	properties := make(map[string]any)
+	g.addProperties(t, t.Name(), properties)
+
+	def := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if required := schemaRequired[t.Name()]; len(required) > 0 {
+		def["required"] = required
+	}
+	g.defs[t.Name()] = def
+
+	return ref
+}
+
+// adds the properties of the fields of a struct, including inlined structs
+func (g *schemaGenerator) addProperties(t reflect.Type, owner string, properties map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			g.addProperties(field.Type, owner, properties)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		property := g.schemaOf(field.Type)
+		if enum, ok := schemaEnums[owner+"."+name]; ok {
+			property["enum"] = enum
+		}
+		properties[name] = property
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Selection restricts a run to some of the metrics of a configuration. Metrics
+// are given by their key or name.
+type Selection struct {
+	Only    []string // Collect only these metrics
+	Exclude []string // Do not collect these metrics
+	Tags    []string // Collect only metrics with at least one of these tags
+}
+
+// reports whether the selection selects all metrics
+func (s Selection) Empty() bool {
+	return len(s.Only) == 0 && len(s.Exclude) == 0 && len(s.Tags) == 0
+}
+
+// returns a copy of the configuration containing only the selected metrics.
+// Metrics and tags that are not in the configuration are an error, so that a
+// typo does not silently select nothing.
+func (s Selection) Apply(cfg *Config) (*Config, error) {
+	for _, metric := range append(slices.Clone(s.Only), s.Exclude...) {
+		if !hasMetric(cfg, metric) {
+			return nil, fmt.Errorf("metric %s not found in configuration", metric)
+		}
+	}
+	for _, tag := range s.Tags {
+		if !hasTag(cfg, tag) {
+			return nil, fmt.Errorf("no metric has tag %s", tag)
+		}
+	}
+
+	selected := &Config{Metrics: make(map[string]MetricConfig)}
+	for key, metric := range cfg.Metrics {
+		if len(s.Only) > 0 && !matchesMetric(s.Only, key, metric) {
+			continue
+		}
+		if matchesMetric(s.Exclude, key, metric) {
+			continue
+		}
+		if len(s.Tags) > 0 && !slices.ContainsFunc(metric.Tags, func(tag string) bool { return slices.Contains(s.Tags, tag) }) {
+			continue
+		}
+		selected.Metrics[key] = metric
+	}
+
+	if len(selected.Metrics) == 0 {
+		return nil, fmt.Errorf("no metrics selected")
+	}
+
+	// Derived metrics are computed from the metrics collected in the same run,
+	// so the metrics they read are selected with them
+	for key := range selected.Metrics {
+		for _, input := range cfg.DerivedInputs(key) {
+			if matchesMetric(s.Exclude, input, cfg.Metrics[input]) {
+				return nil, fmt.Errorf("derived metric %s reads metric %s, which is excluded", key, input)
+			}
+			selected.Metrics[input] = cfg.Metrics[input]
+		}
+	}
+
+	return selected, nil
+}
+
+// reports whether the key or name of a metric is one of the given metrics
+func matchesMetric(metrics []string, key string, metric MetricConfig) bool {
+	return slices.Contains(metrics, key) || slices.Contains(metrics, metric.Name)
+}
+
+func hasMetric(cfg *Config, metric string) bool {
+	for key, metricCfg := range cfg.Metrics {
+		if key == metric || metricCfg.Name == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(cfg *Config, tag string) bool {
+	for _, metricCfg := range cfg.Metrics {
+		if slices.Contains(metricCfg.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,29 +1,100 @@
 package config
 
+import "time"
+
 type Config struct {
 	Metrics map[string]MetricConfig `yaml:"metrics"`
 }
 
 type MetricConfig struct {
-	Name      string          `yaml:"name"`
-	Type      string          `yaml:"type"`
-	Help      string          `yaml:"help"`
-	Collector CollectorConfig `yaml:"collector"`
+	Name        string          `yaml:"name"`
+	Type        string          `yaml:"type"`
+	Help        string          `yaml:"help"`
+	Output      string          `yaml:"output,omitempty"`       // Output group, written to <output>.prom instead of the default file
+	Tags        []string        `yaml:"tags,omitempty"`         // Tags selecting the metric with run -tags
+	CollectedAt bool            `yaml:"collected_at,omitempty"` // Emit a <name>_collected_at_seconds series with the last successful collection time
+	MaxAge      time.Duration   `yaml:"max_age,omitempty"`      // Drop the metric if it was not collected successfully within this duration
+	OnFailure   string          `yaml:"on_failure,omitempty"`   // "default", "keep_last" or "drop"
+	Buckets     []float64       `yaml:"buckets,omitempty"`      // Upper bounds of histogram buckets
+	Quantiles   []float64       `yaml:"quantiles,omitempty"`    // Quantiles of a summary
+	States      []string        `yaml:"states,omitempty"`       // Possible states of a stateset (default: keys of parse.string_map)
+	Collector   CollectorConfig `yaml:"collector"`
+	Tests       []MetricTest    `yaml:"tests,omitempty"` // Fixture test cases run by the test-config command
+}
+
+// test case of a metric, collected from a fixture instead of executing the command
+type MetricTest struct {
+	Name       string            `yaml:"name"`
+	Output     string            `yaml:"output,omitempty"`      // Command output of the fixture
+	OutputFile string            `yaml:"output_file,omitempty"` // File containing the command output, relative to the configuration file
+	ExitCode   int               `yaml:"exit_code,omitempty"`   // Exit code of the fixture
+	Value      *float64          `yaml:"value,omitempty"`       // Expected value of the single series
+	Labels     map[string]string `yaml:"labels,omitempty"`      // Expected labels of the single series
+	Series     []ExpectedSeries  `yaml:"series,omitempty"`      // Expected series of collectors emitting several
+	Error      string            `yaml:"error,omitempty"`       // Expected part of the error message
+}
+
+// series expected from a fixture test
+type ExpectedSeries struct {
+	Name   string            `yaml:"name,omitempty"` // Defaults to the metric name
+	Value  float64           `yaml:"value"`
+	Labels map[string]string `yaml:"labels,omitempty"`
 }
 
 type CollectorConfig struct {
-	Type    string             `yaml:"type"`
-	Command string             `yaml:"command"`
-	Labels  map[string]string  `yaml:"labels"`
-	Mapping map[string]float64 `yaml:"mapping,omitempty"`
-	Parse   *ParseConfig       `yaml:"parse,omitempty"`
+	Type       string             `yaml:"type"`
+	Command    string             `yaml:"command"`
+	Labels     map[string]string  `yaml:"labels"`
+	Mapping    map[string]float64 `yaml:"mapping,omitempty"`
+	Parse      *ParseConfig       `yaml:"parse,omitempty"`
+	Derived    *DerivedConfig     `yaml:"derived,omitempty"`
+	KV         *KVParseConfig     `yaml:"kv,omitempty"`
+	Table      *TableParseConfig  `yaml:"table,omitempty"`
+	Accumulate bool               `yaml:"accumulate,omitempty"` // Add each observation to a persisted running total (counters only)
+	CacheTTL   time.Duration      `yaml:"cache_ttl,omitempty"`  // Reuse the previous successful result for this long instead of executing the command
+	Options    map[string]any     `yaml:"options,omitempty"`    // Settings of collector types registered by programs embedding the exporter
 }
 
 type ParseConfig struct {
+	Mode         string             `yaml:"mode,omitempty"` // "value" (default), "line_count", "match_count" or "match_exists"
 	Pattern      string             `yaml:"pattern"`
 	Index        int                `yaml:"index"`
-	ValueType    string             `yaml:"value_type,omitempty"`    // "float", "int", "bool", "string" etc...
+	ValueType    string             `yaml:"value_type,omitempty"`    // "float", "int", "bool", "bool_nonzero", "bytes", "duration", "percent" or "timestamp"
 	StringMap    map[string]float64 `yaml:"string_map,omitempty"`    // String-to-number mapping
 	Multiplier   float64            `yaml:"multiplier,omitempty"`    // Numeric value to multiply the extracted value by
 	DefaultValue *float64           `yaml:"default_value,omitempty"` // Default value if parsing fails
+	TimeLayout   string             `yaml:"time_layout,omitempty"`   // Go time layout of "timestamp" values (default: RFC 3339)
+	Expression   string             `yaml:"expression,omitempty"`    // Arithmetic over named captures and "value", e.g. "(used / total) * 100"
+}
+
+type DerivedConfig struct {
+	Function    string           `yaml:"function"` // "sum", "min", "max", "avg", "count" or "ratio"
+	Selector    `yaml:",inline"` // Series to compute the value from
+	Denominator *Selector        `yaml:"denominator,omitempty"` // Series to divide by for "ratio"
+}
+
+type Selector struct {
+	Metric string            `yaml:"metric"`          // Metric name
+	Match  map[string]string `yaml:"match,omitempty"` // Label values the series must have
+}
+
+type KVParseConfig struct {
+	Separator    string   `yaml:"separator,omitempty"`     // Separator between key and value (default: the first ":" or "=")
+	Mode         string   `yaml:"mode,omitempty"`          // "metric" (default): each key becomes <name>_<key>; "label": each key becomes a value of key_label
+	KeyLabel     string   `yaml:"key_label,omitempty"`     // Label holding the key in "label" mode (default: "key")
+	Keys         []string `yaml:"keys,omitempty"`          // Keys to use (default: all keys with a numeric value)
+	ValuePattern string   `yaml:"value_pattern,omitempty"` // Regular expression whose first capture group extracts the number from a value
+	ValueType    string   `yaml:"value_type,omitempty"`    // Value type as in parse.value_type
+	TimeLayout   string   `yaml:"time_layout,omitempty"`   // Go time layout of "timestamp" values
+	Multiplier   float64  `yaml:"multiplier,omitempty"`    // Numeric value to multiply the extracted values by
+}
+
+type TableParseConfig struct {
+	Delimiter    string   `yaml:"delimiter,omitempty"`     // Column delimiter (default: whitespace)
+	SkipLines    int      `yaml:"skip_lines,omitempty"`    // Lines before the header row
+	ValueColumn  string   `yaml:"value_column"`            // Header of the column holding the value
+	LabelColumns []string `yaml:"label_columns,omitempty"` // Headers of the columns used as labels
+	ValueType    string   `yaml:"value_type,omitempty"`    // Value type as in parse.value_type
+	TimeLayout   string   `yaml:"time_layout,omitempty"`   // Go time layout of "timestamp" values
+	Multiplier   float64  `yaml:"multiplier,omitempty"`    // Numeric value to multiply the extracted values by
 }
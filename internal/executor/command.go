@@ -12,6 +12,8 @@ type ExecuteCommandResult struct {
 	Output     string
 	ExitCode   int
 	Successful bool
+	Duration   time.Duration
+	TimedOut   bool
 	Error      error
 }
 
@@ -42,12 +44,14 @@ func ExecuteCommandWithResult(commandStr string, timeoutSec int) ExecuteCommandR
 		Setpgid: true,
 	}
 
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
 
 	result := ExecuteCommandResult{
 		Output:     string(output),
 		ExitCode:   0,
 		Successful: (err == nil),
+		Duration:   time.Since(start),
 		Error:      err,
 	}
 
@@ -59,6 +63,7 @@ func ExecuteCommandWithResult(commandStr string, timeoutSec int) ExecuteCommandR
 				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
 			}
 			result.ExitCode = 124 // Using 124 as timeout exit code (like timeout command)
+			result.TimedOut = true
 			result.Error = fmt.Errorf("command timed out after %d seconds: %w", timeoutSec, ctx.Err())
 		} else if exitErr, ok := err.(*exec.ExitError); ok {
 			// Normal command execution error ( non-zero exit code )
@@ -0,0 +1,36 @@
+package executor
+
+import "fmt"
+
+// Executor executes the commands of collectors
+type Executor interface {
+	Execute(commandStr string, timeoutSec int) ExecuteCommandResult
+}
+
+// executes commands with sh -c
+type ShellExecutor struct{}
+
+// executes the command with ExecuteCommandWithResult
+func (ShellExecutor) Execute(commandStr string, timeoutSec int) ExecuteCommandResult {
+	return ExecuteCommandWithResult(commandStr, timeoutSec)
+}
+
+// does not execute commands but returns a fixed output and exit code, as if
+// every command had printed and returned them
+type FixtureExecutor struct {
+	Output   string
+	ExitCode int
+}
+
+// returns the fixture output and exit code
+func (e FixtureExecutor) Execute(commandStr string, timeoutSec int) ExecuteCommandResult {
+	result := ExecuteCommandResult{
+		Output:     e.Output,
+		ExitCode:   e.ExitCode,
+		Successful: e.ExitCode == 0,
+	}
+	if e.ExitCode != 0 {
+		result.Error = fmt.Errorf("exit status %d", e.ExitCode)
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// result of a command as stored in a recording file
+type Recording struct {
+	Output          string  `json:"output"`
+	ExitCode        int     `json:"exit_code"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	TimedOut        bool    `json:"timed_out,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// recording file, mapping each command to its last result
+type recordingFile struct {
+	Commands map[string]Recording `json:"commands"`
+}
+
+// executes commands with another executor and records their results, so they
+// can be replayed later with a ReplayExecutor
+type RecordingExecutor struct {
+	executor   Executor
+	mu         sync.Mutex
+	recordings map[string]Recording
+}
+
+// creates a RecordingExecutor executing commands with exec
+func NewRecordingExecutor(exec Executor) *RecordingExecutor {
+	return &RecordingExecutor{
+		executor:   exec,
+		recordings: make(map[string]Recording),
+	}
+}
+
+// executes the command and records its result
+func (e *RecordingExecutor) Execute(commandStr string, timeoutSec int) ExecuteCommandResult {
+	result := e.executor.Execute(commandStr, timeoutSec)
+
+	recording := Recording{
+		Output:          result.Output,
+		ExitCode:        result.ExitCode,
+		DurationSeconds: result.Duration.Seconds(),
+		TimedOut:        result.TimedOut,
+	}
+	if result.Error != nil {
+		recording.Error = result.Error.Error()
+	}
+
+	e.mu.Lock()
+	e.recordings[commandStr] = recording
+	e.mu.Unlock()
+
+	return result
+}
+
+// writes the recorded results to a file
+func (e *RecordingExecutor) Save(path string) error {
+	e.mu.Lock()
+	data, err := json.MarshalIndent(recordingFile{Commands: e.recordings}, "", "  ")
+	e.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not encode recording: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write recording: %w", err)
+	}
+
+	return nil
+}
+
+// returns recorded results instead of executing commands
+type ReplayExecutor struct {
+	recordings map[string]Recording
+}
+
+// creates a ReplayExecutor from a file written by RecordingExecutor.Save
+func LoadReplayExecutor(path string) (*ReplayExecutor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read recording: %w", err)
+	}
+
+	var file recordingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse recording %s: %w", path, err)
+	}
+
+	return &ReplayExecutor{recordings: file.Commands}, nil
+}
+
+// returns the recorded result of the command, or an error if it was not recorded
+func (e *ReplayExecutor) Execute(commandStr string, timeoutSec int) ExecuteCommandResult {
+	recording, ok := e.recordings[commandStr]
+	if !ok {
+		return ExecuteCommandResult{
+			ExitCode:   127,
+			Successful: false,
+			Error:      fmt.Errorf("no recording of command: %s", commandStr),
+		}
+	}
+
+	result := ExecuteCommandResult{
+		Output:     recording.Output,
+		ExitCode:   recording.ExitCode,
+		Successful: recording.Error == "",
+		Duration:   time.Duration(recording.DurationSeconds * float64(time.Second)),
+		TimedOut:   recording.TimedOut,
+	}
+	if recording.Error != "" {
+		result.Error = errors.New(recording.Error)
+	}
+
+	return result
+}
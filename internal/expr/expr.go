@@ -0,0 +1,286 @@
+// Package expr evaluates arithmetic expressions over named values, e.g.
+// "(used / total) * 100". Only numbers, variables, parentheses and the
+// operators +, -, * and / are supported.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// a parsed expression
+type Expr struct {
+	root node
+}
+
+// node of the expression tree
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type numberNode float64
+
+type variableNode string
+
+type unaryNode struct {
+	operand node
+}
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+// parses an expression
+func Parse(source string) (*Expr, error) {
+	p := &parser{input: source}
+	p.next()
+
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %w", source, err)
+	}
+	if p.token.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression '%s': unexpected %s", source, p.token)
+	}
+
+	return &Expr{root: root}, nil
+}
+
+// evaluates the expression with the given variable values
+func (e *Expr) Eval(vars map[string]float64) (float64, error) {
+	return e.root.eval(vars)
+}
+
+// returns the sorted names of the variables referenced by the expression
+func (e *Expr) Variables() []string {
+	seen := make(map[string]bool)
+	collectVariables(e.root, seen)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func collectVariables(n node, seen map[string]bool) {
+	switch n := n.(type) {
+	case variableNode:
+		seen[string(n)] = true
+	case unaryNode:
+		collectVariables(n.operand, seen)
+	case binaryNode:
+		collectVariables(n.left, seen)
+		collectVariables(n.right, seen)
+	}
+}
+
+func (n numberNode) eval(vars map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n variableNode) eval(vars map[string]float64) (float64, error) {
+	value, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("undefined variable '%s'", string(n))
+	}
+	return value, nil
+}
+
+func (n unaryNode) eval(vars map[string]float64) (float64, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	return -value, nil
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	default:
+		return 0, fmt.Errorf("unknown operator '%c'", n.op)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenInvalid
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// recursive descent parser
+type parser struct {
+	input string
+	pos   int
+	token token
+}
+
+// advances to the next token
+func (p *parser) next() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		p.token = token{kind: tokenEOF}
+		return
+	}
+
+	start := p.pos
+	c := rune(p.input[p.pos])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.input) && (unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		text := p.input[start:p.pos]
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.token = token{kind: tokenInvalid, text: text}
+			return
+		}
+		p.token = token{kind: tokenNumber, text: text, value: value}
+	case unicode.IsLetter(c) || c == '_':
+		for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '_') {
+			p.pos++
+		}
+		p.token = token{kind: tokenIdent, text: p.input[start:p.pos]}
+	case strings.ContainsRune("+-*/()", c):
+		p.pos++
+		p.token = token{kind: tokenOperator, text: string(c)}
+	default:
+		p.pos++
+		p.token = token{kind: tokenInvalid, text: string(c)}
+	}
+}
+
+// expr := term (("+" | "-") term)*
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("+") || p.isOperator("-") {
+		op := p.token.text[0]
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// term := unary (("*" | "/") unary)*
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("*") || p.isOperator("/") {
+		op := p.token.text[0]
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// unary := ("-" | "+") unary | primary
+func (p *parser) parseUnary() (node, error) {
+	switch {
+	case p.isOperator("-"):
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{operand: operand}, nil
+	case p.isOperator("+"):
+		p.next()
+		return p.parseUnary()
+	default:
+		return p.parsePrimary()
+	}
+}
+
+// primary := number | variable | "(" expr ")"
+func (p *parser) parsePrimary() (node, error) {
+	switch {
+	case p.token.kind == tokenNumber:
+		n := numberNode(p.token.value)
+		p.next()
+		return n, nil
+	case p.token.kind == tokenIdent:
+		n := variableNode(p.token.text)
+		p.next()
+		return n, nil
+	case p.isOperator("("):
+		p.next()
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, fmt.Errorf("expected ')' but got %s", p.token)
+		}
+		p.next()
+		return inner, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s", p.token)
+	}
+}
+
+func (p *parser) isOperator(op string) bool {
+	return p.token.kind == tokenOperator && p.token.text == op
+}
@@ -0,0 +1,116 @@
+package expr
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{"used": 25, "total": 200, "value": 3, "free_bytes2": 50}
+
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"42", 42},
+		{"1.5", 1.5},
+		{"value", 3},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"2 * 3 + 4 * 5", 26},
+		{"10 - 2 * 3", 4},
+		{"(used / total) * 100", 12.5},
+		{"used / total * 100", 12.5},
+		{"-value", -3},
+		{"--value", 3},
+		{"+value", 3},
+		{"-2 * 3", -6},
+		{"2 * -3", -6},
+		{"-(1 + 2)", -3},
+		{"1 - -1", 2},
+		{"((value))", 3},
+		{"  value  *  2 ", 6},
+		{"free_bytes2 / 2", 25},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.source)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.source, err)
+			continue
+		}
+		got, err := e.Eval(vars)
+		if err != nil {
+			t.Errorf("Parse(%q).Eval() error = %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q).Eval() = %g, want %g", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vars := map[string]float64{"zero": 0, "value": 1}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"1 / 0", "division by zero"},
+		{"value / zero", "division by zero"},
+		{"value / (1 - 1)", "division by zero"},
+		{"missing * 2", "undefined variable 'missing'"},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.source)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.source, err)
+			continue
+		}
+		_, err = e.Eval(vars)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q).Eval() error = %v, want %q", tt.source, err, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"1 +",
+		"* 2",
+		"(1 + 2",
+		"1 + 2)",
+		"()",
+		"1 2",
+		"value value",
+		"1 % 2",
+		"2 ** 3",
+		"1..2",
+		"value$",
+	}
+
+	for _, source := range tests {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("Parse(%q) error = nil, want error", source)
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	e, err := Parse("(used / total) * 100 + used - value")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []string{"total", "used", "value"}
+	if got := e.Variables(); !slices.Equal(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// increments the counter persisted in path, creating it if needed
+func IncrementCounter(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open counter file: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock counter file: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	count, err := readCount(file)
+	if err != nil {
+		return err
+	}
+
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate counter file: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.FormatUint(count+1, 10)+"\n"), 0); err != nil {
+		return fmt.Errorf("failed to write counter file: %w", err)
+	}
+
+	return nil
+}
+
+// reads the counter persisted in path, which is zero if it does not exist
+func ReadCounter(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open counter file: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
+		return 0, fmt.Errorf("failed to lock counter file: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	return readCount(file)
+}
+
+// parses the counter value from the start of file
+func readCount(file *os.File) (uint64, error) {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, 64))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read counter file: %w", err)
+	}
+
+	str := strings.TrimSpace(string(data))
+	if str == "" {
+		return 0, nil
+	}
+
+	count, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid counter file content: %w", err)
+	}
+
+	return count, nil
+}
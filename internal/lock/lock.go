@@ -0,0 +1,55 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// interval between attempts to acquire a held lock
+const retryInterval = 100 * time.Millisecond
+
+// ErrLocked is returned when the lock is held by another process
+var ErrLocked = errors.New("lock is held by another process")
+
+// advisory lock backed by flock on a lock file
+type FileLock struct {
+	file *os.File
+}
+
+// acquires an exclusive lock on path, waiting up to timeout for another holder to
+// release it. A zero timeout tries exactly once and returns ErrLocked if held.
+func Acquire(path string, timeout time.Duration) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return &FileLock{file: file}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if !time.Now().Before(deadline) {
+			file.Close()
+			return nil, ErrLocked
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// releases the lock
+func (l *FileLock) Release() error {
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	return l.file.Close()
+}
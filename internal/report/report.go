@@ -0,0 +1,174 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+)
+
+// maximum number of bytes of command output kept in a report entry
+const outputExcerptLimit = 1024
+
+// structured summary of a single run
+type Report struct {
+	ConfigFile      string         `json:"config_file"`
+	StartedAt       time.Time      `json:"started_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Metrics         []MetricReport `json:"metrics"`
+}
+
+// outcome of collecting a single configured metric
+type MetricReport struct {
+	Key             string             `json:"key"`
+	Name            string             `json:"name"`
+	CollectorType   string             `json:"collector_type"`
+	Command         string             `json:"command"`
+	ExitCode        int                `json:"exit_code"`
+	DurationSeconds float64            `json:"duration_seconds"`
+	Output          string             `json:"output"`
+	Value           *float64           `json:"value"`
+	Series          []collector.Metric `json:"series,omitempty"`
+	Collected       bool               `json:"collected"`
+	DefaultUsed     bool               `json:"default_used"`
+	KeptLast        bool               `json:"kept_last"`
+	Cached          bool               `json:"cached"`
+	Error           string             `json:"error,omitempty"`
+}
+
+// encodes a non-finite value as the string "NaN", "+Inf" or "-Inf", as JSON
+// numbers cannot represent them
+func (m MetricReport) MarshalJSON() ([]byte, error) {
+	type plain MetricReport
+	return json.Marshal(struct {
+		plain
+		Value *collector.JSONFloat `json:"value"`
+	}{plain(m), (*collector.JSONFloat)(m.Value)})
+}
+
+// creates a new report for a run starting now
+func New(configFile string) *Report {
+	return &Report{
+		ConfigFile: configFile,
+		StartedAt:  time.Now(),
+		Metrics:    []MetricReport{},
+	}
+}
+
+// records the result of a collector for the metric configured under key
+func (r *Report) Add(key string, metricConfig config.MetricConfig, result collector.CollectResult) {
+	entry := MetricReport{
+		Key:             key,
+		Name:            metricConfig.Name,
+		CollectorType:   metricConfig.Collector.Type,
+		Command:         metricConfig.Collector.Command,
+		ExitCode:        result.ExitCode,
+		DurationSeconds: result.Duration.Seconds(),
+		Output:          Excerpt(result.Output),
+		DefaultUsed:     result.DefaultUsed,
+		KeptLast:        result.KeptLast,
+		Cached:          result.Cached,
+	}
+
+	if result.MetricValid {
+		entry.Collected = true
+
+		// A single value for the common case, all series for collectors producing
+		// several and for histograms, summaries and statesets, whose value is not
+		// a single number
+		if len(result.Metrics) == 1 && hasSingleValue(metricConfig.Type) {
+			value := result.Metrics[0].Value
+			entry.Value = &value
+		} else {
+			entry.Series = result.Metrics
+		}
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+
+	r.Metrics = append(r.Metrics, entry)
+}
+
+// reports whether a metric of the type is a single number. The series of a
+// stateset record its current state in the state field.
+func hasSingleValue(metricType string) bool {
+	switch metricType {
+	case "histogram", "summary", "stateset":
+		return false
+	}
+	return true
+}
+
+// records a metric whose collector could not be created
+func (r *Report) AddError(key string, metricConfig config.MetricConfig, err error) {
+	r.Metrics = append(r.Metrics, MetricReport{
+		Key:           key,
+		Name:          metricConfig.Name,
+		CollectorType: metricConfig.Collector.Type,
+		Command:       metricConfig.Collector.Command,
+		Error:         err.Error(),
+	})
+}
+
+// counts the metrics that were collected with a warning, and those that were not collected
+func (r *Report) Problems() (warnings, errors int) {
+	for _, entry := range r.Metrics {
+		switch {
+		case !entry.Collected:
+			errors++
+		case entry.Error != "":
+			warnings++
+		}
+	}
+	return warnings, errors
+}
+
+// marks the run as finished
+func (r *Report) Finish() {
+	r.DurationSeconds = time.Since(r.StartedAt).Seconds()
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteJSONFile writes the report as JSON to a file with atomic write
+func (r *Report) WriteJSONFile(reportFile string) error {
+	tmpfile, err := os.CreateTemp(filepath.Dir(reportFile), "report.*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if err := r.WriteJSON(tmpfile); err != nil {
+		tmpfile.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if err := tmpfile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpfile.Name(), reportFile); err != nil {
+		return fmt.Errorf("failed to move temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// truncates command output to a size suitable for log pipelines
+func Excerpt(output string) string {
+	if len(output) <= outputExcerptLimit {
+		return output
+	}
+	return output[:outputExcerptLimit] + "...(truncated)"
+}
@@ -0,0 +1,200 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+	"github.com/zinrai/prom-textfile-exporter/internal/lock"
+)
+
+const (
+	stateFileName = "state.json"
+	lockFileName  = "state.lock"
+)
+
+// information about a configured metric that is kept across runs
+type MetricState struct {
+	LastSuccess time.Time          `json:"last_success,omitzero"`  // Last time the metric was collected successfully
+	LastMetrics []collector.Metric `json:"last_metrics,omitempty"` // Metrics of the last successful collection
+	Total       float64            `json:"total,omitempty"`        // Running total of accumulated observations
+	Command     string             `json:"command,omitempty"`      // Command the running total was accumulated from
+	Cache       *Cache             `json:"cache,omitempty"`        // Last successful result, reused while within cache_ttl
+}
+
+func (m MetricState) MarshalJSON() ([]byte, error) {
+	type plain MetricState
+	return json.Marshal(struct {
+		plain
+		Total collector.JSONFloat `json:"total,omitempty"`
+	}{plain(m), collector.JSONFloat(m.Total)})
+}
+
+func (m *MetricState) UnmarshalJSON(data []byte) error {
+	type plain MetricState
+	aux := struct {
+		*plain
+		Total collector.JSONFloat `json:"total,omitempty"`
+	}{plain: (*plain)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	m.Total = float64(aux.Total)
+	return nil
+}
+
+// a cached collection result
+type Cache struct {
+	CollectedAt time.Time          `json:"collected_at"`
+	Command     string             `json:"command"`
+	Metrics     []collector.Metric `json:"metrics"`
+	ExitCode    int                `json:"exit_code"`
+}
+
+// persisted per-metric state, locked for exclusive use while open
+type Store struct {
+	Metrics map[string]*MetricState `json:"metrics"`
+
+	dir  string
+	lock *lock.FileLock
+}
+
+// opens the store in dir, waiting up to timeout for other runs using it.
+// An empty dir gives an in-memory store that is not persisted.
+func Open(dir string, timeout time.Duration) (*Store, error) {
+	store := &Store{
+		Metrics: make(map[string]*MetricState),
+		dir:     dir,
+	}
+
+	if dir == "" {
+		return store, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	stateLock, err := lock.Acquire(filepath.Join(dir, lockFileName), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state directory: %w", err)
+	}
+	store.lock = stateLock
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		stateLock.Release()
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		stateLock.Release()
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if store.Metrics == nil {
+		store.Metrics = make(map[string]*MetricState)
+	}
+
+	return store, nil
+}
+
+// returns the state of the metric identified by key, creating it if needed
+func (s *Store) Metric(key string) *MetricState {
+	metricState, ok := s.Metrics[key]
+	if !ok {
+		metricState = &MetricState{}
+		s.Metrics[key] = metricState
+	}
+	return metricState
+}
+
+// removes the state of all metrics whose key is not one of keys
+func (s *Store) Retain(keys []string) {
+	for key := range s.Metrics {
+		if !slices.Contains(keys, key) {
+			delete(s.Metrics, key)
+		}
+	}
+}
+
+// reports whether the store is persisted across runs
+func (s *Store) Persistent() bool {
+	return s.dir != ""
+}
+
+// persists the store and releases its lock
+func (s *Store) Close() error {
+	if s.dir == "" {
+		return nil
+	}
+	defer s.lock.Release()
+
+	// Encode each metric on its own, so that the state of one metric that
+	// cannot be encoded is left out instead of preventing all state from being saved
+	var encodeErrs []error
+	metrics := make(map[string]json.RawMessage, len(s.Metrics))
+	for key, metricState := range s.Metrics {
+		data, err := json.Marshal(metricState)
+		if err != nil {
+			encodeErrs = append(encodeErrs, fmt.Errorf("failed to encode state of %s: %w", key, err))
+			continue
+		}
+		metrics[key] = data
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Metrics map[string]json.RawMessage `json:"metrics"`
+	}{metrics}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmpfile, err := os.CreateTemp(s.dir, "state.*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write(data); err != nil {
+		tmpfile.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmpfile.Sync(); err != nil {
+		tmpfile.Close()
+		return fmt.Errorf("failed to sync state file: %w", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		return fmt.Errorf("failed to close state file: %w", err)
+	}
+
+	if err := os.Rename(tmpfile.Name(), filepath.Join(s.dir, stateFileName)); err != nil {
+		return fmt.Errorf("failed to move state file: %w", err)
+	}
+
+	return errors.Join(encodeErrs...)
+}
+
+// returns the key identifying a series by metric name and labels
+func Key(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(parts)
+
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,76 @@
+package writer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestName returns the name of the manifest that tracks the files written for outputFile
+func ManifestName(outputFile string) string {
+	return "." + strings.TrimSuffix(outputFile, ".prom") + ".manifest"
+}
+
+// RemoveStaleFiles removes files recorded in the manifest that are no longer part of
+// the current set of output files, then records the current set in the manifest.
+// Only files previously written by this tool are ever removed.
+func RemoveStaleFiles(dir, manifestName string, current []string) error {
+	manifestPath := filepath.Join(dir, manifestName)
+
+	previous, err := readManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(current))
+	for _, name := range current {
+		keep[name] = true
+	}
+
+	for _, name := range previous {
+		if keep[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale file %s: %w", name, err)
+		}
+	}
+
+	content := strings.Join(current, "\n") + "\n"
+	if err := os.WriteFile(manifestPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// reads the file names recorded in a manifest, which may not exist yet
+func readManifest(manifestPath string) ([]string, error) {
+	file, err := os.Open(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		// Ignore anything that is not a plain file name in the output directory
+		if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return names, nil
+}
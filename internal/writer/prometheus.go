@@ -4,82 +4,209 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/zinrai/prom-textfile-exporter/internal/collector"
 )
 
-// formatMetrics formats metrics in Prometheus format
-func formatMetrics(metrics []collector.Metric) string {
+// FormatMetrics formats metrics in Prometheus format
+func FormatMetrics(metrics []collector.Metric) string {
 	var sb strings.Builder
 
-	uniqueMetrics := make(map[string]bool)
-
+	// All series of a metric name must be contiguous, so group them by name
+	// in order of first appearance
+	var names []string
+	families := make(map[string][]collector.Metric)
 	for _, metric := range metrics {
+		if _, ok := families[metric.Name]; !ok {
+			names = append(names, metric.Name)
+		}
+		families[metric.Name] = append(families[metric.Name], metric)
+	}
+
+	for _, name := range names {
+		family := families[name]
+
 		// Add HELP and TYPE lines only once per metric name
-		if !uniqueMetrics[metric.Name] {
-			fmt.Fprintf(&sb, "# HELP %s %s\n", metric.Name, metric.Help)
-			fmt.Fprintf(&sb, "# TYPE %s %s\n", metric.Name, metric.Type)
-			uniqueMetrics[metric.Name] = true
+		fmt.Fprintf(&sb, "# HELP %s %s\n", name, helpEscaper.Replace(family[0].Help))
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", name, exposedType(family[0].Type))
+
+		for _, metric := range family {
+			formatSamples(&sb, metric)
 		}
+	}
 
-		// Format labels if any
-		labelsStr := ""
-		if len(metric.Labels) > 0 {
-			var labelParts []string
-			for k, v := range metric.Labels {
-				labelParts = append(labelParts, fmt.Sprintf("%s=%q", k, v))
+	return sb.String()
+}
+
+// formatSamples writes the sample lines of a single series
+func formatSamples(sb *strings.Builder, metric collector.Metric) {
+	switch metric.Type {
+	case "histogram":
+		for _, bucket := range metric.Buckets {
+			le := strconv.FormatFloat(bucket.UpperBound, 'g', -1, 64)
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", metric.Name, formatLabels(withLabel(metric.Labels, "le", le)), bucket.Count)
+		}
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", metric.Name, formatLabels(withLabel(metric.Labels, "le", "+Inf")), metric.Count)
+		fmt.Fprintf(sb, "%s_sum%s %g\n", metric.Name, formatLabels(metric.Labels), metric.Sum)
+		fmt.Fprintf(sb, "%s_count%s %d\n", metric.Name, formatLabels(metric.Labels), metric.Count)
+	case "summary":
+		for _, quantile := range metric.Quantiles {
+			q := strconv.FormatFloat(quantile.Quantile, 'g', -1, 64)
+			fmt.Fprintf(sb, "%s%s %g\n", metric.Name, formatLabels(withLabel(metric.Labels, "quantile", q)), quantile.Value)
+		}
+		fmt.Fprintf(sb, "%s_sum%s %g\n", metric.Name, formatLabels(metric.Labels), metric.Sum)
+		fmt.Fprintf(sb, "%s_count%s %d\n", metric.Name, formatLabels(metric.Labels), metric.Count)
+	case "stateset":
+		// One series per state, labelled with the metric name as in OpenMetrics
+		for _, state := range metric.States {
+			value := 0
+			if state == metric.State {
+				value = 1
 			}
-			labelsStr = fmt.Sprintf("{%s}", strings.Join(labelParts, ","))
+			fmt.Fprintf(sb, "%s%s %d\n", metric.Name, formatLabels(withLabel(metric.Labels, metric.Name, state)), value)
 		}
+	default:
+		fmt.Fprintf(sb, "%s%s %g\n", metric.Name, formatLabels(metric.Labels), metric.Value)
+	}
+}
 
-		// Add metric line
-		fmt.Fprintf(&sb, "%s%s %g\n", metric.Name, labelsStr, metric.Value)
+// exposedType returns the type written to the TYPE line. The textfile collector
+// only understands the Prometheus text format, which has no info and stateset
+// types, so these are exposed as gauges.
+func exposedType(metricType string) string {
+	switch metricType {
+	case "info", "stateset":
+		return "gauge"
+	default:
+		return metricType
 	}
+}
 
-	return sb.String()
+// withLabel returns a copy of labels with an additional label
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+// escapes label values as the text format requires. Label values may come from
+// command output, and any other escape, such as Go's \t or \u200b, makes
+// node_exporter reject the whole file, so all other UTF-8 is written as is.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapes HELP text, which may not contain a raw newline
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// formatLabels formats labels sorted by name, or an empty string if there are none
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var labelParts []string
+	for k, v := range labels {
+		labelParts = append(labelParts, k+`="`+labelValueEscaper.Replace(strings.ToValidUTF8(v, "\uFFFD"))+`"`)
+	}
+	sort.Strings(labelParts)
+
+	return fmt.Sprintf("{%s}", strings.Join(labelParts, ","))
 }
 
 // WriteMetricsToStdout writes metrics in Prometheus format to stdout
 func WriteMetricsToStdout(metrics []collector.Metric) error {
-	content := formatMetrics(metrics)
+	content := FormatMetrics(metrics)
 	_, err := fmt.Print(content)
 	return err
 }
 
+// FileOptions controls the permissions and ownership of written metric files
+type FileOptions struct {
+	Mode os.FileMode // Permission bits of the written file
+	UID  int         // Owner user ID, -1 to leave unchanged
+	GID  int         // Owner group ID, -1 to leave unchanged
+}
+
+// DefaultFileOptions returns options producing files readable by other users, such as node_exporter
+func DefaultFileOptions() FileOptions {
+	return FileOptions{
+		Mode: 0644,
+		UID:  -1,
+		GID:  -1,
+	}
+}
+
 // WriteMetricsToFile writes metrics in Prometheus format to a file with atomic write
-func WriteMetricsToFile(metrics []collector.Metric, outputFile string) error {
-	content := formatMetrics(metrics)
+func WriteMetricsToFile(metrics []collector.Metric, outputFile string, opts FileOptions) (err error) {
+	content := FormatMetrics(metrics)
 
-	// Create a temporary file
+	// Create a temporary file, hidden and without the .prom suffix so that
+	// node_exporter does not read it while it is being written
 	dir := filepath.Dir(outputFile)
-	tmpfile, err := os.CreateTemp(dir, "metrics.*.prom")
+	tmpfile, err := os.CreateTemp(dir, ".metrics.*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary file: %w", err)
 	}
 
 	// Clean up on error
 	defer func() {
-		tmpfile.Close()
 		if err != nil {
+			tmpfile.Close()
 			os.Remove(tmpfile.Name())
 		}
 	}()
 
 	// Write content to temporary file
-	if _, err := tmpfile.WriteString(content); err != nil {
+	if _, err = tmpfile.WriteString(content); err != nil {
 		return fmt.Errorf("failed to write to temporary file: %w", err)
 	}
 
+	// CreateTemp always uses 0600, which the reading user may not have access to
+	if err = tmpfile.Chmod(opts.Mode); err != nil {
+		return fmt.Errorf("failed to set mode of temporary file: %w", err)
+	}
+
+	if opts.UID != -1 || opts.GID != -1 {
+		if err = tmpfile.Chown(opts.UID, opts.GID); err != nil {
+			return fmt.Errorf("failed to set owner of temporary file: %w", err)
+		}
+	}
+
+	// Flush the content to disk before it becomes visible under the final name
+	if err = tmpfile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+
 	// Close the file before renaming
-	if err := tmpfile.Close(); err != nil {
+	if err = tmpfile.Close(); err != nil {
 		return fmt.Errorf("failed to close temporary file: %w", err)
 	}
 
 	// Atomically move the file to its final destination
-	if err := os.Rename(tmpfile.Name(), outputFile); err != nil {
+	if err = os.Rename(tmpfile.Name(), outputFile); err != nil {
 		return fmt.Errorf("failed to move temporary file: %w", err)
 	}
 
+	// Persist the rename itself
+	if err = syncDir(dir); err != nil {
+		return fmt.Errorf("failed to sync output directory: %w", err)
+	}
+
 	return nil
 }
+
+// syncDir flushes directory entries, such as a completed rename, to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,67 @@
+package writer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+)
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{nil, ""},
+		{map[string]string{"b": "2", "a": "1"}, `{a="1",b="2"}`},
+		{map[string]string{"version": "1.2\tbeta \u200bx"}, "{version=\"1.2\tbeta \u200bx\"}"},
+		{map[string]string{"path": `C:\tmp`}, `{path="C:\\tmp"}`},
+		{map[string]string{"quote": `say "hi"`}, `{quote="say \"hi\""}`},
+		{map[string]string{"lines": "a\nb"}, `{lines="a\nb"}`},
+		{map[string]string{"unicode": "température °C"}, `{unicode="température °C"}`},
+		{map[string]string{"invalid": "a\xffb"}, "{invalid=\"a\uFFFDb\"}"},
+	}
+
+	for _, tt := range tests {
+		if got := formatLabels(tt.labels); got != tt.want {
+			t.Errorf("formatLabels(%q) = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMetricsEscapesHelp(t *testing.T) {
+	metrics := []collector.Metric{{Name: "up", Type: "gauge", Help: "first line\nsecond \\ line", Value: 1}}
+
+	want := "# HELP up first line\\nsecond \\\\ line\n# TYPE up gauge\nup 1\n"
+	if got := FormatMetrics(metrics); got != want {
+		t.Errorf("FormatMetrics() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteMetricsToFile(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "test.prom")
+	metrics := []collector.Metric{{Name: "test_metric", Type: "gauge", Help: "Test", Value: 1}}
+
+	if err := WriteMetricsToFile(metrics, outputFile, DefaultFileOptions()); err != nil {
+		t.Fatalf("WriteMetricsToFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := FormatMetrics(metrics); string(data) != want {
+		t.Errorf("file content = %q, want %q", data, want)
+	}
+
+	// The temporary file must not be left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory contains %d files, want 1", len(entries))
+	}
+}
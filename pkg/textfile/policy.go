@@ -0,0 +1,187 @@
+package textfile
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/state"
+)
+
+// reports whether a result is a real observation, as default values and
+// timeouts do not reflect the current state of what is measured
+func isObservation(result CollectResult) bool {
+	return result.MetricValid && !result.DefaultUsed && !result.KeptLast && !result.Cached && !result.TimedOut
+}
+
+// returns the cached result of a metric if it has a cache_ttl and the cache
+// entry is still within it
+func cachedResult(logger *slog.Logger, store *state.Store, metricCfg MetricConfig, now time.Time) (CollectResult, bool) {
+	if metricCfg.Collector.CacheTTL == 0 {
+		return CollectResult{}, false
+	}
+
+	cache := store.Metric(state.Key(metricCfg.Name, metricCfg.Collector.Labels)).Cache
+	if cache == nil || cache.Command != metricCfg.Collector.Command || now.Sub(cache.CollectedAt) > metricCfg.Collector.CacheTTL {
+		return CollectResult{}, false
+	}
+
+	logger.Debug("Using cached result", "metric", metricCfg.Name, "collected_at", cache.CollectedAt.Format(time.RFC3339))
+
+	return CollectResult{
+		Metrics:     cache.Metrics,
+		MetricValid: true,
+		ExitCode:    cache.ExitCode,
+		Cached:      true,
+	}, true
+}
+
+// stores a successful result of a metric with a cache_ttl in the cache
+func updateCache(store *state.Store, metricCfg MetricConfig, result CollectResult, now time.Time) {
+	if metricCfg.Collector.CacheTTL == 0 || !isObservation(result) {
+		return
+	}
+
+	store.Metric(state.Key(metricCfg.Name, metricCfg.Collector.Labels)).Cache = &state.Cache{
+		CollectedAt: now,
+		Command:     metricCfg.Collector.Command,
+		Metrics:     result.Metrics,
+		ExitCode:    result.ExitCode,
+	}
+}
+
+// applies the on_failure policy of a metric to a result that is not a real observation
+func applyFailurePolicy(store *state.Store, metricCfg MetricConfig, result *CollectResult) {
+	if isObservation(*result) {
+		return
+	}
+
+	switch metricCfg.OnFailure {
+	case "drop":
+		result.MetricValid = false
+		if result.Error == nil {
+			result.Error = fmt.Errorf("no observation (dropping metric)")
+		}
+	case "keep_last":
+		metricState := store.Metric(state.Key(metricCfg.Name, metricCfg.Collector.Labels))
+		if metricState.LastMetrics == nil {
+			// Nothing to keep yet, so fall back to the default behavior
+			return
+		}
+
+		result.Metrics = metricState.LastMetrics
+		result.MetricValid = true
+		result.DefaultUsed = false
+		result.KeptLast = true
+		result.HasWarning = true
+		if result.Error != nil {
+			result.Error = fmt.Errorf("%w (keeping last value)", result.Error)
+		} else {
+			result.Error = fmt.Errorf("no observation (keeping last value)")
+		}
+	}
+}
+
+// flags a metric with a cache_ttl as collected with a warning if there is no
+// state directory to cache its result in, as its command then runs every time
+func checkCacheable(store *state.Store, metricCfg MetricConfig, result *CollectResult) {
+	if metricCfg.Collector.CacheTTL == 0 || store.Persistent() || !result.MetricValid {
+		return
+	}
+
+	err := fmt.Errorf("cache_ttl requires a state directory (-state-dir), executing the command on every run")
+	if result.Error != nil {
+		err = fmt.Errorf("%w; %w", result.Error, err)
+	}
+	result.Error = err
+	result.HasWarning = true
+}
+
+// adds an observation to the persisted running total of a counter and replaces
+// the result value with the total. Results that are not a real observation, and
+// negative observations, leave the total unchanged.
+func applyAccumulate(logger *slog.Logger, store *state.Store, metricCfg MetricConfig, result *CollectResult) {
+	if !store.Persistent() {
+		result.MetricValid = false
+		result.Error = fmt.Errorf("accumulate requires a state directory (-state-dir)")
+		return
+	}
+
+	metricState := store.Metric(state.Key(metricCfg.Name, metricCfg.Collector.Labels))
+
+	// A different command measures something else, so start a new total
+	if metricState.Command != metricCfg.Collector.Command {
+		if metricState.Command != "" {
+			logger.Warn("Command of accumulated metric changed, resetting total", "metric", metricCfg.Name)
+		}
+		metricState.Total = 0
+		metricState.Command = metricCfg.Collector.Command
+	}
+
+	if !result.MetricValid {
+		return
+	}
+
+	if len(result.Metrics) != 1 {
+		result.MetricValid = false
+		result.Error = fmt.Errorf("accumulate requires a single series, got %d", len(result.Metrics))
+		return
+	}
+
+	switch {
+	case !isObservation(*result):
+		if result.Error == nil {
+			result.Error = fmt.Errorf("no observation to accumulate (keeping total)")
+		}
+		result.HasWarning = true
+	case result.Metrics[0].Value < 0:
+		result.Error = fmt.Errorf("negative observation %g cannot be accumulated (keeping total)", result.Metrics[0].Value)
+		result.HasWarning = true
+	default:
+		metricState.Total += result.Metrics[0].Value
+	}
+
+	result.Metrics[0].Value = metricState.Total
+}
+
+// records successful collections of a metric and enforces its max_age, dropping
+// a result, including a kept last value, that has not been successful recently enough. Returns the companion
+// _collected_at_seconds series if the metric requests one.
+func applyFreshness(store *state.Store, metricCfg MetricConfig, result *CollectResult, now time.Time) *Metric {
+	metricState := store.Metric(state.Key(metricCfg.Name, metricCfg.Collector.Labels))
+
+	if isObservation(*result) {
+		metricState.LastSuccess = now
+		metricState.LastMetrics = result.Metrics
+	}
+	// Only keep_last emits the last metrics, so do not keep them otherwise
+	if metricCfg.OnFailure != "keep_last" {
+		metricState.LastMetrics = nil
+	}
+
+	if !result.MetricValid {
+		return nil
+	}
+
+	if metricCfg.MaxAge > 0 && (metricState.LastSuccess.IsZero() || now.Sub(metricState.LastSuccess) > metricCfg.MaxAge) {
+		result.MetricValid = false
+		if result.Error != nil {
+			result.Error = fmt.Errorf("not collected successfully within max_age of %s: %w", metricCfg.MaxAge, result.Error)
+		} else {
+			result.Error = fmt.Errorf("not collected successfully within max_age of %s", metricCfg.MaxAge)
+		}
+		return nil
+	}
+
+	if !metricCfg.CollectedAt || metricState.LastSuccess.IsZero() {
+		return nil
+	}
+
+	return &Metric{
+		Name:   metricCfg.Name + "_collected_at_seconds",
+		Value:  float64(metricState.LastSuccess.Unix()),
+		Type:   "gauge",
+		Help:   fmt.Sprintf("Unix time of the last successful collection of %s", metricCfg.Name),
+		Labels: metricCfg.Collector.Labels,
+	}
+}
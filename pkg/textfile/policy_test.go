@@ -0,0 +1,199 @@
+package textfile
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/state"
+)
+
+// opens an in-memory store, or a persisted one in a temporary directory
+func openStore(t *testing.T, persistent bool) *state.Store {
+	t.Helper()
+
+	dir := ""
+	if persistent {
+		dir = t.TempDir()
+	}
+	store, err := state.Open(dir, time.Second)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func gaugeConfig(onFailure string) MetricConfig {
+	return MetricConfig{
+		Name:      "test_metric",
+		Type:      "gauge",
+		OnFailure: onFailure,
+		Collector: CollectorConfig{Type: "output_parse", Command: "echo 1", Parse: &ParseConfig{Pattern: `(\d+)`, Index: 1}},
+	}
+}
+
+func valueResult(value float64) CollectResult {
+	return CollectResult{Metrics: []Metric{{Name: "test_metric", Type: "gauge", Value: value}}, MetricValid: true}
+}
+
+func TestApplyFailurePolicy(t *testing.T) {
+	failed := CollectResult{Error: errors.New("command execution failed")}
+	defaulted := valueResult(-1)
+	defaulted.DefaultUsed = true
+
+	tests := []struct {
+		name      string
+		onFailure string
+		last      []Metric
+		result    CollectResult
+		wantValid bool
+		wantValue float64
+		wantKept  bool
+	}{
+		{name: "observation", onFailure: "drop", result: valueResult(3), wantValid: true, wantValue: 3},
+		{name: "default keeps failure", result: failed},
+		{name: "default keeps default value", result: defaulted, wantValid: true, wantValue: -1},
+		{name: "drop failure", onFailure: "drop", result: failed},
+		{name: "drop default value", onFailure: "drop", result: defaulted},
+		{name: "keep_last without last", onFailure: "keep_last", result: defaulted, wantValid: true, wantValue: -1},
+		{name: "keep_last failure", onFailure: "keep_last", last: valueResult(2).Metrics, result: failed, wantValid: true, wantValue: 2, wantKept: true},
+		{name: "keep_last default value", onFailure: "keep_last", last: valueResult(2).Metrics, result: defaulted, wantValid: true, wantValue: 2, wantKept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openStore(t, false)
+			metricCfg := gaugeConfig(tt.onFailure)
+			store.Metric(state.Key(metricCfg.Name, nil)).LastMetrics = tt.last
+
+			result := tt.result
+			applyFailurePolicy(store, metricCfg, &result)
+
+			if result.MetricValid != tt.wantValid {
+				t.Fatalf("MetricValid = %v, want %v (error %v)", result.MetricValid, tt.wantValid, result.Error)
+			}
+			if tt.wantValid && result.Metrics[0].Value != tt.wantValue {
+				t.Errorf("value = %g, want %g", result.Metrics[0].Value, tt.wantValue)
+			}
+			if result.KeptLast != tt.wantKept {
+				t.Errorf("KeptLast = %v, want %v", result.KeptLast, tt.wantKept)
+			}
+			if !tt.wantValid && result.Error == nil {
+				t.Errorf("error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestApplyAccumulate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	timedOut := valueResult(5)
+	timedOut.TimedOut = true
+
+	tests := []struct {
+		name        string
+		persistent  bool
+		command     string
+		results     []CollectResult
+		wantValid   bool
+		wantTotal   float64
+		wantWarning bool
+	}{
+		{name: "adds observations", persistent: true, results: []CollectResult{valueResult(2), valueResult(3)}, wantValid: true, wantTotal: 5},
+		{name: "keeps total on negative observation", persistent: true, results: []CollectResult{valueResult(2), valueResult(-1)}, wantValid: true, wantTotal: 2, wantWarning: true},
+		{name: "keeps total on timeout", persistent: true, results: []CollectResult{valueResult(2), timedOut}, wantValid: true, wantTotal: 2, wantWarning: true},
+		{name: "resets total when command changes", persistent: true, command: "echo 2", results: []CollectResult{valueResult(2), valueResult(3)}, wantValid: true, wantTotal: 3},
+		{name: "requires state directory", results: []CollectResult{valueResult(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openStore(t, tt.persistent)
+			metricCfg := gaugeConfig("")
+			metricCfg.Type = "counter"
+
+			var result CollectResult
+			for i, r := range tt.results {
+				if i == len(tt.results)-1 && tt.command != "" {
+					metricCfg.Collector.Command = tt.command
+				}
+				result = r
+				applyAccumulate(logger, store, metricCfg, &result)
+			}
+
+			if result.MetricValid != tt.wantValid {
+				t.Fatalf("MetricValid = %v, want %v (error %v)", result.MetricValid, tt.wantValid, result.Error)
+			}
+			if !tt.wantValid {
+				return
+			}
+			if result.Metrics[0].Value != tt.wantTotal {
+				t.Errorf("value = %g, want %g", result.Metrics[0].Value, tt.wantTotal)
+			}
+			if result.HasWarning != tt.wantWarning {
+				t.Errorf("HasWarning = %v, want %v (error %v)", result.HasWarning, tt.wantWarning, result.Error)
+			}
+		})
+	}
+}
+
+func TestApplyFreshness(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defaulted := valueResult(-1)
+	defaulted.DefaultUsed = true
+
+	tests := []struct {
+		name          string
+		onFailure     string
+		maxAge        time.Duration
+		collectedAt   bool
+		lastSuccess   time.Time
+		result        CollectResult
+		wantValid     bool
+		wantCompanion bool
+		wantLast      bool
+	}{
+		{name: "observation", result: valueResult(1), wantValid: true},
+		{name: "observation with collected_at", collectedAt: true, result: valueResult(1), wantValid: true, wantCompanion: true},
+		{name: "keep_last stores last metrics", onFailure: "keep_last", result: valueResult(1), wantValid: true, wantLast: true},
+		{name: "within max_age", maxAge: time.Hour, lastSuccess: now.Add(-time.Minute), result: defaulted, wantValid: true},
+		{name: "beyond max_age", maxAge: time.Hour, lastSuccess: now.Add(-2 * time.Hour), result: defaulted},
+		{name: "never successful with max_age", maxAge: time.Hour, result: defaulted},
+		{name: "default value without collected_at companion", collectedAt: true, result: defaulted, wantValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openStore(t, false)
+			metricCfg := gaugeConfig(tt.onFailure)
+			metricCfg.MaxAge = tt.maxAge
+			metricCfg.CollectedAt = tt.collectedAt
+			metricState := store.Metric(state.Key(metricCfg.Name, nil))
+			metricState.LastSuccess = tt.lastSuccess
+			// Left over from a configuration that used keep_last
+			metricState.LastMetrics = valueResult(7).Metrics
+
+			result := tt.result
+			companion := applyFreshness(store, metricCfg, &result, now)
+
+			if result.MetricValid != tt.wantValid {
+				t.Fatalf("MetricValid = %v, want %v (error %v)", result.MetricValid, tt.wantValid, result.Error)
+			}
+			if (companion != nil) != tt.wantCompanion {
+				t.Errorf("companion = %v, want %v", companion, tt.wantCompanion)
+			}
+			if companion != nil && companion.Value != float64(now.Unix()) {
+				t.Errorf("companion value = %g, want %d", companion.Value, now.Unix())
+			}
+			if gotLast := metricState.LastMetrics != nil; gotLast != tt.wantLast {
+				t.Errorf("LastMetrics stored = %v, want %v", gotLast, tt.wantLast)
+			}
+			if tt.wantLast && metricState.LastMetrics[0].Value != result.Metrics[0].Value {
+				t.Errorf("LastMetrics value = %g, want %g", metricState.LastMetrics[0].Value, result.Metrics[0].Value)
+			}
+		})
+	}
+}
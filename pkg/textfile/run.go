@@ -0,0 +1,179 @@
+package textfile
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+	"github.com/zinrai/prom-textfile-exporter/internal/report"
+	"github.com/zinrai/prom-textfile-exporter/internal/state"
+)
+
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultLockTimeout = 30 * time.Second
+)
+
+// runs the collectors of a configuration. The zero value executes commands
+// with the shell and a timeout of 10 seconds, without persisting state.
+type Runner struct {
+	Timeout     time.Duration // Command execution timeout (default: 10 seconds)
+	StateDir    string        // Directory for state kept across runs (default: not persisted)
+	LockTimeout time.Duration // Time to wait for another run holding the state lock (default: 30 seconds)
+	Executor    Executor      // Executes the commands of collectors (default: the shell)
+	Logger      *slog.Logger  // Logs the collection of each metric (default: slog.Default())
+	Configured  *Config       // All configured metrics, whose state is kept when only some are run (default: the configuration run)
+}
+
+// collects all metrics of the configuration with the default Runner
+func Run(ctx context.Context, cfg *Config) ([]Metric, *Report, error) {
+	var runner Runner
+	return runner.Run(ctx, cfg)
+}
+
+// collects all metrics of the configuration, applying caching, failure
+// policies, accumulation and freshness. Metrics that cannot be collected are
+// left out and recorded in the report. An error is returned only if the state
+// cannot be used. Cancelling the context skips the metrics not collected yet.
+func (r *Runner) Run(ctx context.Context, cfg *Config) ([]Metric, *Report, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	timeoutSec := int(timeout.Round(time.Second) / time.Second)
+	if timeoutSec < 1 {
+		timeoutSec = 1
+	}
+
+	lockTimeout := r.LockTimeout
+	if lockTimeout <= 0 {
+		lockTimeout = defaultLockTimeout
+	}
+
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	store, err := state.Open(r.StateDir, lockTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open state: %w", err)
+	}
+
+	metrics := []Metric{}
+	runReport := report.New("")
+
+	for _, name := range CollectionOrder(cfg) {
+		metricCfg := cfg.Metrics[name]
+
+		if err := ctx.Err(); err != nil {
+			runReport.AddError(name, metricCfg, err)
+			continue
+		}
+
+		collectorType := metricCfg.Collector.Type
+		logger.Debug("Collecting metric", "metric", name, "collector_type", collectorType)
+
+		col, err := collector.NewCollector(metricCfg, timeoutSec, r.Executor)
+		if err != nil {
+			logger.Error("Failed to create collector", "metric", name, "collector_type", collectorType, "error", err)
+			runReport.AddError(name, metricCfg, err)
+			continue
+		}
+
+		if inputCollector, ok := col.(collector.InputCollector); ok {
+			inputCollector.SetInputs(metrics)
+		}
+
+		now := time.Now()
+		result, cached := cachedResult(logger, store, metricCfg, now)
+		if !cached {
+			result = col.Collect()
+			applyFailurePolicy(store, metricCfg, &result)
+			checkCacheable(store, metricCfg, &result)
+			if metricCfg.Collector.Accumulate {
+				applyAccumulate(logger, store, metricCfg, &result)
+			}
+		}
+		companion := applyFreshness(store, metricCfg, &result, now)
+		if !cached {
+			updateCache(store, metricCfg, result, now)
+		}
+		runReport.Add(name, metricCfg, result)
+
+		attrs := []any{
+			"metric", name,
+			"collector_type", collectorType,
+			"exit_code", result.ExitCode,
+			"duration", result.Duration,
+		}
+		if result.Output != "" {
+			logger.Debug("Command output", append(attrs, "output", report.Excerpt(result.Output))...)
+		}
+		if result.Error != nil {
+			attrs = append(attrs, "error", result.Error)
+			// If there is an error but valid metrics
+			if result.MetricValid {
+				logger.Warn("Metric collected with a warning", attrs...)
+			} else {
+				// If there is an error and no valid metrics
+				logger.Error("Failed to collect metric", attrs...)
+			}
+		} else {
+			logger.Debug("Collected metric", attrs...)
+		}
+		if !result.MetricValid {
+			continue
+		}
+
+		collected := append([]Metric{}, result.Metrics...)
+		if companion != nil {
+			collected = append(collected, *companion)
+		}
+		for i := range collected {
+			collected[i].Output = metricCfg.Output
+		}
+		metrics = append(metrics, collected...)
+	}
+
+	// Forget the state of metrics that are no longer configured
+	configured := r.Configured
+	if configured == nil {
+		configured = cfg
+	}
+	keys := make([]string, 0, len(configured.Metrics))
+	for _, metricCfg := range configured.Metrics {
+		keys = append(keys, state.Key(metricCfg.Name, metricCfg.Collector.Labels))
+	}
+	store.Retain(keys)
+
+	if err := store.Close(); err != nil {
+		logger.Warn("Failed to save state", "error", err)
+	}
+
+	runReport.Finish()
+
+	return metrics, runReport, nil
+}
+
+// returns the metric keys in the order they are collected: sorted, so that
+// output and reports are reproducible, with collectors reading other metrics,
+// such as derived metrics, last as they use the metrics collected before them
+func CollectionOrder(cfg *Config) []string {
+	names := make([]string, 0, len(cfg.Metrics))
+	readsInputs := make(map[string]bool, len(cfg.Metrics))
+	for name, metricCfg := range cfg.Metrics {
+		names = append(names, name)
+		readsInputs[name] = collector.ReadsInputs(metricCfg)
+	}
+	sort.Strings(names)
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return !readsInputs[names[i]] && readsInputs[names[j]]
+	})
+
+	return names
+}
@@ -0,0 +1,49 @@
+package textfile
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+	"github.com/zinrai/prom-textfile-exporter/internal/state"
+)
+
+func TestRunnerRetainsConfiguredState(t *testing.T) {
+	dir := t.TempDir()
+	store, err := state.Open(dir, time.Second)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	store.Metric("removed_metric").Total = 1
+	store.Metric("other_metric").Total = 2
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	run := gaugeConfig("")
+	other := gaugeConfig("")
+	other.Name = "other_metric"
+	runner := Runner{
+		StateDir:   dir,
+		Executor:   executor.FixtureExecutor{Output: "1\n"},
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Configured: &Config{Metrics: map[string]MetricConfig{"run": run, "other": other}},
+	}
+	if _, _, err := runner.Run(t.Context(), &Config{Metrics: map[string]MetricConfig{"run": run}}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	store, err = state.Open(dir, time.Second)
+	if err != nil {
+		t.Fatalf("state.Open() error = %v", err)
+	}
+	defer store.Close()
+	if _, ok := store.Metrics["removed_metric"]; ok {
+		t.Errorf("state of removed_metric was kept")
+	}
+	if metricState, ok := store.Metrics["other_metric"]; !ok || metricState.Total != 2 {
+		t.Errorf("state of other_metric = %v, want total 2", metricState)
+	}
+}
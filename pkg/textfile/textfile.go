@@ -0,0 +1,123 @@
+// Package textfile exposes the collection engine of prom-textfile-exporter for
+// embedding in other programs. It loads configurations, runs their collectors
+// and writes the resulting metrics in the Prometheus text format.
+package textfile
+
+import (
+	"io"
+
+	"github.com/zinrai/prom-textfile-exporter/internal/collector"
+	"github.com/zinrai/prom-textfile-exporter/internal/config"
+	"github.com/zinrai/prom-textfile-exporter/internal/executor"
+	"github.com/zinrai/prom-textfile-exporter/internal/report"
+	"github.com/zinrai/prom-textfile-exporter/internal/writer"
+)
+
+type (
+	// configuration of all metrics
+	Config = config.Config
+	// configuration of a single metric
+	MetricConfig = config.MetricConfig
+	// configuration of the collector of a metric
+	CollectorConfig = config.CollectorConfig
+	// configuration of an output_parse collector
+	ParseConfig = config.ParseConfig
+	// configuration of a derived collector
+	DerivedConfig = config.DerivedConfig
+	// series a derived collector computes its value from
+	Selector = config.Selector
+	// configuration of a kv_parse collector
+	KVParseConfig = config.KVParseConfig
+	// configuration of a table_parse collector
+	TableParseConfig = config.TableParseConfig
+	// fixture test case of a metric
+	MetricTest = config.MetricTest
+	// series expected from a fixture test
+	ExpectedSeries = config.ExpectedSeries
+	// restricts a run to some metrics of a configuration, see Selection.Apply
+	Selection = config.Selection
+
+	// a collected series
+	Metric = collector.Metric
+	// histogram bucket of a collected series
+	Bucket = collector.Bucket
+	// summary quantile of a collected series
+	Quantile = collector.Quantile
+	// collects the series of a single metric
+	Collector = collector.Collector
+	// outcome of a collection
+	CollectResult = collector.CollectResult
+
+	// executes the commands of collectors
+	Executor = executor.Executor
+	// result of an executed command
+	ExecuteCommandResult = executor.ExecuteCommandResult
+	// executes commands with sh -c
+	ShellExecutor = executor.ShellExecutor
+
+	// structured summary of a run
+	Report = report.Report
+	// outcome of collecting a single metric in a run
+	MetricReport = report.MetricReport
+
+	// creates the collector of a metric of a collector type
+	CollectorFactory = collector.Factory
+	// validates the collector configuration of a collector type
+	CollectorValidator = config.CollectorValidator
+	// constructor and configuration validator of a collector type
+	CollectorRegistration = collector.Registration
+
+	// permissions and ownership of written metric files
+	FileOptions = writer.FileOptions
+)
+
+// registers a custom collector type, which configurations select with
+// collector.type and configure with collector.options. Registering a type
+// twice, including a built-in type, panics.
+func RegisterCollector(collectorType string, registration CollectorRegistration) {
+	collector.Register(collectorType, registration)
+}
+
+// returns the sorted names of all registered collector types
+func CollectorTypes() []string {
+	return collector.Types()
+}
+
+// loads and validates a configuration file
+func LoadConfig(configFile string) (*Config, error) {
+	return config.LoadConfig(configFile)
+}
+
+// validates a configuration built in code. Configurations must be valid
+// before they are run.
+func ValidateConfig(cfg *Config) error {
+	return config.ValidateConfig(cfg)
+}
+
+// creates the collector of a metric, executing its command with exec. A nil
+// executor executes commands with the shell.
+func NewCollector(metricConfig MetricConfig, timeoutSec int, exec Executor) (Collector, error) {
+	return collector.NewCollector(metricConfig, timeoutSec, exec)
+}
+
+// formats metrics in the Prometheus text format
+func FormatMetrics(metrics []Metric) string {
+	return writer.FormatMetrics(metrics)
+}
+
+// writes metrics in the Prometheus text format to w
+func WriteMetrics(w io.Writer, metrics []Metric) error {
+	_, err := io.WriteString(w, writer.FormatMetrics(metrics))
+	return err
+}
+
+// writes metrics to a file with atomic write, so the textfile collector never
+// reads a partial file
+func WriteMetricsToFile(metrics []Metric, outputFile string, opts FileOptions) error {
+	return writer.WriteMetricsToFile(metrics, outputFile, opts)
+}
+
+// returns the default file options: mode 0644, owned by the current user
+func DefaultFileOptions() FileOptions {
+	return writer.DefaultFileOptions()
+}